@@ -0,0 +1,852 @@
+package cmds
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// The CBOR encoding (RFC 7049) sends every value as a data item, one after
+// the other. Values are mapped like with encoding/json: structs become maps
+// keyed by field name, honoring the cbor or else the json struct tag, byte
+// slices become byte strings and encoding.TextMarshalers text strings.
+// Errors, progress and warnings are maps with a Type key, like in JSON.
+
+// CBOR major types
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+const (
+	cborFalse = 0xf4
+	cborTrue  = 0xf5
+	cborNull  = 0xf6
+	cborBreak = 0xff
+
+	// cborIndefinite is the additional information of items of
+	// indefinite length.
+	cborIndefinite = 31
+)
+
+// maxCBORLen limits the length of strings, arrays and maps a decoder
+// accepts, so a corrupt length can't make it allocate huge amounts of
+// memory. maxCBORDepth limits the nesting of arrays and maps.
+const (
+	maxCBORLen   = 64 << 20
+	maxCBORDepth = 1000
+)
+
+type cborEncoder struct {
+	w io.Writer
+}
+
+func (e *cborEncoder) Encode(v interface{}) error {
+	if s, ok := v.(Single); ok {
+		v = s.Value
+	}
+
+	b, err := appendCBOR(nil, reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+// cborMarked returns errors, progress and warnings as maps marked with their
+// type, see MaybeError.
+func cborMarked(v interface{}) (map[string]interface{}, bool) {
+	switch val := v.(type) {
+	case cmdkit.Error:
+		return map[string]interface{}{"Message": val.Message, "Code": uint64(val.Code), "Type": "error"}, true
+	case *cmdkit.Error:
+		if val == nil {
+			return nil, false
+		}
+		return cborMarked(*val)
+	case Progress:
+		return map[string]interface{}{"Done": val.Done, "Total": val.Total, "Unit": string(val.Unit), "Type": "progress"}, true
+	case Warning:
+		return map[string]interface{}{"Message": val.Message, "Type": "warning"}, true
+	}
+	return nil, false
+}
+
+func appendCBOR(b []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(b, cborNull), nil
+	}
+
+	// unwrap interfaces first, the checks below need the dynamic type
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return append(b, cborNull), nil
+		}
+		return appendCBOR(b, v.Elem())
+	}
+
+	if v.CanInterface() {
+		if m, ok := cborMarked(v.Interface()); ok {
+			return appendCBOR(b, reflect.ValueOf(m))
+		}
+
+		if v.Type().Implements(textMarshalerType) && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+			text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return nil, err
+			}
+			b = appendCBORHead(b, cborText, uint64(len(text)))
+			return append(b, text...), nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return append(b, cborNull), nil
+		}
+		return appendCBOR(b, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, cborTrue), nil
+		}
+		return append(b, cborFalse), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		if n < 0 {
+			return appendCBORHead(b, cborNegInt, uint64(-1-n)), nil
+		}
+		return appendCBORHead(b, cborUint, uint64(n)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendCBORHead(b, cborUint, v.Uint()), nil
+	case reflect.Float32:
+		b = append(b, cborSimple<<5|26)
+		return appendUint(b, uint64(math.Float32bits(float32(v.Float()))), 4), nil
+	case reflect.Float64:
+		b = append(b, cborSimple<<5|27)
+		return appendUint(b, math.Float64bits(v.Float()), 8), nil
+	case reflect.String:
+		b = appendCBORHead(b, cborText, uint64(v.Len()))
+		return append(b, v.String()...), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(b, cborNull), nil
+		}
+		fallthrough
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b = appendCBORHead(b, cborBytes, uint64(v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				b = append(b, byte(v.Index(i).Uint()))
+			}
+			return b, nil
+		}
+
+		b = appendCBORHead(b, cborArray, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			var err error
+			b, err = appendCBOR(b, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return append(b, cborNull), nil
+		}
+		return appendCBORMap(b, v)
+	case reflect.Struct:
+		return appendCBORStruct(b, v)
+	default:
+		return nil, fmt.Errorf("can't encode value of type %s as CBOR", v.Type())
+	}
+}
+
+// appendCBORMap encodes a map with the keys sorted like in canonical CBOR,
+// so equal maps are encoded equally.
+func appendCBORMap(b []byte, v reflect.Value) ([]byte, error) {
+	type entry struct {
+		key, value []byte
+	}
+
+	entries := make([]entry, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		key, err := appendCBOR(nil, k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := appendCBOR(nil, v.MapIndex(k))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key, value})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		ki, kj := entries[i].key, entries[j].key
+		if len(ki) != len(kj) {
+			return len(ki) < len(kj)
+		}
+		return bytes.Compare(ki, kj) < 0
+	})
+
+	b = appendCBORHead(b, cborMap, uint64(len(entries)))
+	for _, e := range entries {
+		b = append(b, e.key...)
+		b = append(b, e.value...)
+	}
+	return b, nil
+}
+
+func appendCBORStruct(b []byte, v reflect.Value) ([]byte, error) {
+	fields := cborFields(v.Type())
+
+	values := make([]reflect.Value, 0, len(fields))
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		fv := v.FieldByIndex(f.index)
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		values = append(values, fv)
+		names = append(names, f.name)
+	}
+
+	b = appendCBORHead(b, cborMap, uint64(len(values)))
+	for i, fv := range values {
+		b = appendCBORHead(b, cborText, uint64(len(names[i])))
+		b = append(b, names[i]...)
+
+		var err error
+		b, err = appendCBOR(b, fv)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendCBORHead appends the initial byte and argument of a data item.
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major<<5|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major<<5|24, byte(n))
+	case n <= math.MaxUint16:
+		return appendUint(append(b, major<<5|25), n, 2)
+	case n <= math.MaxUint32:
+		return appendUint(append(b, major<<5|26), n, 4)
+	default:
+		return appendUint(append(b, major<<5|27), n, 8)
+	}
+}
+
+// appendUint appends the size lowest bytes of n in big endian order.
+func appendUint(b []byte, n uint64, size int) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	return append(b, buf[8-size:]...)
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+type cborField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// cborFields returns the fields of struct type t that are encoded. The
+// fields of embedded structs are included like with encoding/json, embedded
+// pointers are not.
+func cborFields(t reflect.Type) []cborField {
+	var fields []cborField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("cbor")
+		if tag == "" {
+			tag = f.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for _, ef := range cborFields(f.Type) {
+				ef.index = append([]int{i}, ef.index...)
+				fields = append(fields, ef)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		field := cborField{name: name, index: []int{i}}
+		for _, opt := range opts[1:] {
+			if opt == "omitempty" {
+				field.omitEmpty = true
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+type cborDecoder struct {
+	r *bufio.Reader
+}
+
+// Decode reads the next data item into v, which must be a pointer. If v is a
+// *MaybeError, errors, progress and warnings are told apart from values like
+// in MaybeError.UnmarshalJSON.
+func (d *cborDecoder) Decode(v interface{}) error {
+	item, err := d.readItem(0)
+	if err != nil {
+		return err
+	}
+
+	if m, ok := v.(*MaybeError); ok {
+		return m.decodeCBOR(item)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("can't decode CBOR into %T", v)
+	}
+	return assignCBOR(rv.Elem(), item)
+}
+
+// readItem reads a data item. Integers are returned as uint64, or int64 if
+// negative, floats as float64, byte strings as []byte, text strings as
+// string, arrays as []interface{} and maps as map[string]interface{}, with
+// keys that are no text strings formatted with fmt.Sprint. Tags are ignored.
+func (d *cborDecoder) readItem(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, errors.New("CBOR data nested too deeply")
+	}
+
+	initial, err := d.r.ReadByte()
+	if err != nil {
+		if err == io.EOF && depth > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	major, info := initial>>5, initial&31
+	if major == cborSimple {
+		return d.readSimple(info)
+	}
+
+	if info == cborIndefinite {
+		return d.readIndefinite(major, depth)
+	}
+
+	n, err := d.readArg(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		return n, nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, errors.New("CBOR integer overflows int64")
+		}
+		return -1 - int64(n), nil
+	case cborBytes, cborText:
+		if n > maxCBORLen {
+			return nil, fmt.Errorf("CBOR string of %d bytes is too large", n)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(d.r, buf); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if major == cborText {
+			return string(buf), nil
+		}
+		return buf, nil
+	case cborArray:
+		if n > maxCBORLen {
+			return nil, fmt.Errorf("CBOR array of %d items is too large", n)
+		}
+		items := make([]interface{}, 0, minLen(n))
+		for i := uint64(0); i < n; i++ {
+			item, err := d.readItem(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case cborMap:
+		if n > maxCBORLen {
+			return nil, fmt.Errorf("CBOR map of %d items is too large", n)
+		}
+		m := make(map[string]interface{}, minLen(n))
+		for i := uint64(0); i < n; i++ {
+			if err := d.readEntry(m, depth); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	default: // cborTag
+		return d.readItem(depth + 1)
+	}
+}
+
+// readIndefinite reads the rest of an item of indefinite length.
+func (d *cborDecoder) readIndefinite(major byte, depth int) (interface{}, error) {
+	var (
+		buf   []byte
+		items = []interface{}{}
+		m     = map[string]interface{}{}
+	)
+
+	for {
+		next, err := d.r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if next == cborBreak {
+			break
+		}
+		d.r.UnreadByte()
+
+		switch major {
+		case cborBytes, cborText:
+			// the chunks are definite strings of the same type
+			if next>>5 != major || next&31 == cborIndefinite {
+				return nil, errors.New("invalid chunk of CBOR string")
+			}
+			chunk, err := d.readItem(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch c := chunk.(type) {
+			case string:
+				buf = append(buf, c...)
+			case []byte:
+				buf = append(buf, c...)
+			}
+			if len(buf) > maxCBORLen {
+				return nil, errors.New("CBOR string is too large")
+			}
+		case cborArray:
+			item, err := d.readItem(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		case cborMap:
+			if err := d.readEntry(m, depth); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("CBOR major type %d can't have indefinite length", major)
+		}
+	}
+
+	switch major {
+	case cborBytes:
+		if buf == nil {
+			buf = []byte{}
+		}
+		return buf, nil
+	case cborText:
+		return string(buf), nil
+	case cborArray:
+		return items, nil
+	default:
+		return m, nil
+	}
+}
+
+// readEntry reads a key and value of a map into m.
+func (d *cborDecoder) readEntry(m map[string]interface{}, depth int) error {
+	key, err := d.readItem(depth + 1)
+	if err != nil {
+		return err
+	}
+	value, err := d.readItem(depth + 1)
+	if err != nil {
+		return err
+	}
+
+	if s, ok := key.(string); ok {
+		m[s] = value
+	} else {
+		m[fmt.Sprint(key)] = value
+	}
+	return nil
+}
+
+// readArg reads the argument of a data item given by the additional
+// information of its initial byte.
+func (d *cborDecoder) readArg(info byte) (uint64, error) {
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, fmt.Errorf("invalid CBOR additional information %d", info)
+	}
+
+	var buf [8]byte
+	if _, err := io.ReadFull(d.r, buf[8-size:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (d *cborDecoder) readSimple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23: // null and undefined
+		return nil, nil
+	case 25, 26, 27:
+		n, err := d.readArg(info)
+		if err != nil {
+			return nil, err
+		}
+		switch info {
+		case 25:
+			return halfFloat(uint16(n)), nil
+		case 26:
+			return float64(math.Float32frombits(uint32(n))), nil
+		default:
+			return math.Float64frombits(n), nil
+		}
+	case cborIndefinite:
+		return nil, errors.New("unexpected CBOR break")
+	default:
+		return nil, fmt.Errorf("unsupported CBOR simple value %d", info)
+	}
+}
+
+// halfFloat converts an IEEE 754 half-precision float.
+func halfFloat(h uint16) float64 {
+	exp, frac := int(h>>10&0x1f), float64(h&0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(frac+0x400, exp-25)
+	}
+
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// minLen bounds the capacity allocated up front for n items.
+func minLen(n uint64) int {
+	if n > 1024 {
+		return 1024
+	}
+	return int(n)
+}
+
+// decodeCBOR is the CBOR equivalent of UnmarshalJSON.
+func (m *MaybeError) decodeCBOR(item interface{}) error {
+	if obj, ok := item.(map[string]interface{}); ok {
+		switch obj["Type"] {
+		case "error":
+			m.isError = true
+			return assignCBOR(reflect.ValueOf(&m.Error).Elem(), obj)
+		case "progress":
+			m.isProgress = true
+			return assignCBOR(reflect.ValueOf(&m.Progress).Elem(), obj)
+		case "warning":
+			m.isWarning = true
+			return assignCBOR(reflect.ValueOf(&m.Warning).Elem(), obj)
+		}
+	}
+
+	if m.Value == nil {
+		m.Value = item
+		return nil
+	}
+
+	// decode into a new value, m.Value may be the Type of the command
+	t := reflect.TypeOf(m.Value)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	p := reflect.New(t)
+	if err := assignCBOR(p.Elem(), item); err != nil {
+		return err
+	}
+	m.Value = p.Interface()
+	return nil
+}
+
+// assignCBOR stores an item returned by readItem in dst, converting it like
+// encoding/json.
+func assignCBOR(dst reflect.Value, item interface{}) error {
+	if item == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if s, ok := item.(string); ok && dst.CanAddr() {
+		if tu, ok := dst.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return tu.UnmarshalText([]byte(s))
+		}
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("can't decode CBOR %T into %s", item, dst.Type())
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		v := reflect.ValueOf(item)
+		if !v.Type().AssignableTo(dst.Type()) {
+			return mismatch()
+		}
+		dst.Set(v)
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assignCBOR(dst.Elem(), item)
+	case reflect.Bool:
+		b, ok := item.(bool)
+		if !ok {
+			return mismatch()
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch x := item.(type) {
+		case uint64:
+			if x > math.MaxInt64 {
+				return fmt.Errorf("CBOR integer %d overflows %s", x, dst.Type())
+			}
+			n = int64(x)
+		case int64:
+			n = x
+		default:
+			return mismatch()
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("CBOR integer %d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x, ok := item.(uint64)
+		if !ok {
+			return mismatch()
+		}
+		if dst.OverflowUint(x) {
+			return fmt.Errorf("CBOR integer %d overflows %s", x, dst.Type())
+		}
+		dst.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		switch x := item.(type) {
+		case float64:
+			dst.SetFloat(x)
+		case uint64:
+			dst.SetFloat(float64(x))
+		case int64:
+			dst.SetFloat(float64(x))
+		default:
+			return mismatch()
+		}
+	case reflect.String:
+		s, ok := item.(string)
+		if !ok {
+			return mismatch()
+		}
+		dst.SetString(s)
+	case reflect.Slice, reflect.Array:
+		return assignCBORList(dst, item)
+	case reflect.Map:
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		return assignCBORMap(dst, obj)
+	case reflect.Struct:
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		return assignCBORStruct(dst, obj)
+	default:
+		return mismatch()
+	}
+	return nil
+}
+
+func assignCBORList(dst reflect.Value, item interface{}) error {
+	var (
+		n   int
+		get func(i int) interface{}
+	)
+	switch x := item.(type) {
+	case []byte:
+		if dst.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("can't decode CBOR byte string into %s", dst.Type())
+		}
+		n, get = len(x), func(i int) interface{} { return uint64(x[i]) }
+	case []interface{}:
+		n, get = len(x), func(i int) interface{} { return x[i] }
+	default:
+		return fmt.Errorf("can't decode CBOR %T into %s", item, dst.Type())
+	}
+
+	if dst.Kind() == reflect.Slice {
+		dst.Set(reflect.MakeSlice(dst.Type(), n, n))
+	} else {
+		// like encoding/json, extra items are dropped and missing ones zeroed
+		dst.Set(reflect.Zero(dst.Type()))
+		if n > dst.Len() {
+			n = dst.Len()
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if err := assignCBOR(dst.Index(i), get(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func assignCBORMap(dst reflect.Value, obj map[string]interface{}) error {
+	t := dst.Type()
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMap(t))
+	}
+
+	for k, item := range obj {
+		key := reflect.New(t.Key()).Elem()
+		switch t.Key().Kind() {
+		case reflect.String:
+			key.SetString(k)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(k, 10, 64)
+			if err != nil || key.OverflowInt(n) {
+				return fmt.Errorf("can't decode CBOR map key %q into %s", k, t.Key())
+			}
+			key.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n, err := strconv.ParseUint(k, 10, 64)
+			if err != nil || key.OverflowUint(n) {
+				return fmt.Errorf("can't decode CBOR map key %q into %s", k, t.Key())
+			}
+			key.SetUint(n)
+		default:
+			return fmt.Errorf("can't decode CBOR map into %s", t)
+		}
+
+		value := reflect.New(t.Elem()).Elem()
+		if err := assignCBOR(value, item); err != nil {
+			return err
+		}
+		dst.SetMapIndex(key, value)
+	}
+	return nil
+}
+
+func assignCBORStruct(dst reflect.Value, obj map[string]interface{}) error {
+	fields := cborFields(dst.Type())
+
+	for k, item := range obj {
+		// prefer an exact match, like encoding/json
+		var field *cborField
+		for i := range fields {
+			if fields[i].name == k {
+				field = &fields[i]
+				break
+			}
+			if field == nil && strings.EqualFold(fields[i].name, k) {
+				field = &fields[i]
+			}
+		}
+		if field == nil {
+			continue
+		}
+
+		if err := assignCBOR(dst.FieldByIndex(field.index), item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
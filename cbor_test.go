@@ -0,0 +1,229 @@
+package cmds
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/hex"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+func TestCBOREncode(t *testing.T) {
+	// examples of RFC 7049, appendix A
+	tcs := []struct {
+		v   interface{}
+		exp string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{uint8(24), "1818"},
+		{1000, "1903e8"},
+		{uint64(1000000000000), "1b000000e8d4a51000"},
+		{-1, "20"},
+		{-1000, "3903e7"},
+		{1.1, "fb3ff199999999999a"},
+		{float32(100000), "fa47c35000"},
+		{true, "f5"},
+		{nil, "f6"},
+		{"a", "6161"},
+		{"ü", "62c3bc"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{[]int{1, 2, 3}, "83010203"},
+		{map[string]interface{}{"b": []int{2, 3}, "a": 1}, "a26161016162820203"},
+		{Single{"a"}, "6161"},
+		{struct{ T encoding.TextMarshaler }{}, "a16154f6"},
+		{struct{ T encoding.TextMarshaler }{net.ParseIP("::1")}, "a16154633a3a31"},
+	}
+
+	for _, tc := range tcs {
+		var buf bytes.Buffer
+		if err := Encoders[CBOR](nil)(&buf).Encode(tc.v); err != nil {
+			t.Errorf("encoding %v: %s", tc.v, err)
+			continue
+		}
+		if s := hex.EncodeToString(buf.Bytes()); s != tc.exp {
+			t.Errorf("expected %v to be encoded as %s, got %s", tc.v, tc.exp, s)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Encoders[CBOR](nil)(&buf).Encode(make(chan int)); err == nil {
+		t.Error("expected error for channel")
+	}
+}
+
+func TestCBORDecode(t *testing.T) {
+	tcs := []struct {
+		data string
+		exp  interface{}
+	}{
+		{"1b000000e8d4a51000", uint64(1000000000000)},
+		{"3903e7", int64(-1000)},
+		{"f93c00", 1.0},
+		{"f97bff", 65504.0},
+		{"f90001", 5.960464477539063e-8},
+		{"f9fc00", math.Inf(-1)},
+		{"fa47c35000", 100000.0},
+		{"f4", false},
+		{"f7", nil},
+		{"c11a514b67b0", uint64(1363896240)},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9fff", []interface{}{}},
+		{"bf61610161629f0203ffff", map[string]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}}},
+		{"a201020304", map[string]interface{}{"1": uint64(2), "3": uint64(4)}},
+	}
+
+	for _, tc := range tcs {
+		data, _ := hex.DecodeString(tc.data)
+		var v interface{}
+		if err := Decoders[CBOR](bytes.NewReader(data)).Decode(&v); err != nil {
+			t.Errorf("decoding %s: %s", tc.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tc.exp) {
+			t.Errorf("expected %s to be decoded as %#v, got %#v", tc.data, tc.exp, v)
+		}
+	}
+
+	for _, data := range []string{
+		"",           // no item
+		"1903",       // truncated argument
+		"830102",     // truncated array
+		"5f4101ff00", // missing break, then garbage
+		"ff",         // break outside of item
+		"1c",         // reserved additional information
+		"5f6161ff",   // text chunk in byte string
+	} {
+		b, _ := hex.DecodeString(data)
+		var v interface{}
+		err := Decoders[CBOR](bytes.NewReader(b)).Decode(&v)
+		if data == "" {
+			if err != io.EOF {
+				t.Errorf("expected EOF, got %v", err)
+			}
+			continue
+		}
+		if data == "5f4101ff00" {
+			if err != nil || !reflect.DeepEqual(v, []byte{1}) {
+				t.Errorf("expected first item to decode, got %#v, %v", v, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("expected error decoding %s, got %#v", data, v)
+		}
+	}
+}
+
+type cborEmbedded struct {
+	Inner string
+}
+
+type cborValue struct {
+	cborEmbedded
+
+	Name    string `json:"name"`
+	Skipped string `json:"-"`
+	Empty   string `json:",omitempty"`
+	Alias   string `cbor:"alias" json:"notthis"`
+	Small   int8
+	Neg     int
+	Ratio   float64
+	Hash    [4]byte
+	Data    []byte
+	List    []*cborEmbedded
+	Ints    map[int]string
+	IP      net.IP
+	Any     interface{}
+	Ptr     *uint
+
+	hidden string
+}
+
+func TestCBORStruct(t *testing.T) {
+	n := uint(7)
+	v := cborValue{
+		cborEmbedded: cborEmbedded{"inner"},
+		Name:         "a",
+		Skipped:      "skipped",
+		Alias:        "alias",
+		Small:        -3,
+		Neg:          -70000,
+		Ratio:        0.5,
+		Hash:         [4]byte{1, 2, 3, 4},
+		Data:         []byte("data"),
+		List:         []*cborEmbedded{{"x"}, nil},
+		Ints:         map[int]string{1: "one", -2: "minus two"},
+		IP:           net.ParseIP("10.0.0.1"),
+		Any:          "any",
+		Ptr:          &n,
+		hidden:       "hidden",
+	}
+
+	var buf bytes.Buffer
+	if err := Encoders[CBOR](nil)(&buf).Encode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	var generic map[string]interface{}
+	if err := Decoders[CBOR](bytes.NewReader(buf.Bytes())).Decode(&generic); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"Skipped", "Empty", "notthis", "hidden", "cborEmbedded"} {
+		if _, ok := generic[key]; ok {
+			t.Errorf("expected no key %q, got %v", key, generic)
+		}
+	}
+	if generic["Inner"] != "inner" || generic["alias"] != "alias" || generic["IP"] != "10.0.0.1" {
+		t.Errorf("unexpected encoding %v", generic)
+	}
+
+	var out cborValue
+	if err := Decoders[CBOR](&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	v.Skipped, v.hidden = "", ""
+	if !reflect.DeepEqual(out, v) {
+		t.Errorf("expected\n%#v\ngot\n%#v", v, out)
+	}
+
+	// overflows and mismatches are errors
+	for _, dst := range []interface{}{new(int8), new(uint), new(string)} {
+		if err := Decoders[CBOR](bytes.NewReader([]byte{0x39, 0x03, 0xe7})).Decode(dst); err == nil {
+			t.Errorf("expected error decoding -1000 into %T", dst)
+		}
+	}
+}
+
+func TestCBORStream(t *testing.T) {
+	req := &Request{Command: &Command{Type: &cborEmbedded{}}, Options: cmdkit.OptMap{EncLong: CBOR}}
+	r, w := io.Pipe()
+	re := NewWriterResponseEmitter(w, req, Encoders[CBOR])
+	res := NewReaderResponse(r, CBOR, req)
+
+	go func() {
+		re.Emit(&cborEmbedded{"a"})
+		Warn(re, "careful")
+		re.Emit(cborEmbedded{"b"})
+		re.SetError("boom", cmdkit.ErrClient)
+		re.Close()
+	}()
+
+	var values []cborEmbedded
+	err := Collect(res, &values)
+	if e, ok := err.(*cmdkit.Error); !ok || e.Message != "boom" || e.Code != cmdkit.ErrClient {
+		t.Errorf("expected the command error, got %#v", err)
+	}
+	if exp := []cborEmbedded{{"a"}, {"b"}}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("expected values %v, got %v", exp, values)
+	}
+	if ws := res.(WarningResponse).Warnings(); !reflect.DeepEqual(ws, []string{"careful"}) {
+		t.Errorf("expected warning, got %v", ws)
+	}
+}
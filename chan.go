@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 )
@@ -21,6 +22,7 @@ func NewChanResponsePair(req *Request) (ResponseEmitter, Response) {
 	}
 
 	re := &chanResponseEmitter{
+		res:    r,
 		ch:     ch,
 		length: &r.length,
 		wait:   wait,
@@ -33,6 +35,17 @@ func NewChanResponsePair(req *Request) (ResponseEmitter, Response) {
 type chanResponse struct {
 	req *Request
 
+	// mu guards warnings, which Warnings may read while Next appends, and
+	// the progress callback and forward, which are set by other goroutines
+	mu       sync.Mutex
+	warnings []string
+
+	onProgress func(Progress)
+
+	// forward is the emitter a PostRun wraps. The progress and warnings Next
+	// consumes are passed on to it, see ApplyPostRun.
+	forward ResponseEmitter
+
 	err    *cmdkit.Error
 	length uint64
 
@@ -88,6 +101,19 @@ func (r *chanResponse) Next() (interface{}, error) {
 			return nil, io.EOF
 		}
 
+		if p, ok := v.(Progress); ok {
+			r.progress(p)
+			return r.Next()
+		}
+
+		if w, ok := v.(Warning); ok {
+			r.addWarning(w.Message)
+			if fwd := r.forwardEmitter(); fwd != nil {
+				Warn(fwd, w.Message)
+			}
+			return r.Next()
+		}
+
 		if err, ok := v.(cmdkit.Error); ok {
 			v = &err
 		}
@@ -122,11 +148,20 @@ func (r *chanResponse) RawNext() (interface{}, error) {
 
 	select {
 	case v, ok := <-r.ch:
-		if ok {
-			return v, nil
+		if !ok {
+			return nil, io.EOF
 		}
 
-		return nil, io.EOF
+		if p, ok := v.(Progress); ok {
+			r.progress(p)
+			return r.RawNext()
+		}
+
+		if w, ok := v.(Warning); ok {
+			r.addWarning(w.Message)
+		}
+
+		return v, nil
 	case <-ctx.Done():
 		close(r.done)
 		return nil, ctx.Err()
@@ -134,7 +169,49 @@ func (r *chanResponse) RawNext() (interface{}, error) {
 
 }
 
+// OnProgress sets the function that is called with the progress reported
+// by the emitter. If set, progress is not forwarded.
+func (r *chanResponse) OnProgress(f func(Progress)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onProgress = f
+}
+
+func (r *chanResponse) progress(p Progress) {
+	r.mu.Lock()
+	f, fwd := r.onProgress, r.forward
+	r.mu.Unlock()
+
+	switch {
+	case f != nil:
+		f(p)
+	case fwd != nil:
+		SetProgress(fwd, p)
+	}
+}
+
+func (r *chanResponse) forwardEmitter() ResponseEmitter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.forward
+}
+
+func (r *chanResponse) addWarning(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warnings = append(r.warnings, msg)
+}
+
+// Warnings returns the warnings received so far.
+func (r *chanResponse) Warnings() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.warnings...)
+}
+
 type chanResponseEmitter struct {
+	res *chanResponse
+
 	ch   chan<- interface{}
 	wait chan struct{}
 	done <-chan struct{}
@@ -189,6 +266,46 @@ func (re *chanResponseEmitter) SetError(v interface{}, errType cmdkit.ErrorType)
 	}
 }
 
+// SetProgress passes p to the progress callback of the response. Like
+// values, it blocks until the response is read.
+func (re *chanResponseEmitter) SetProgress(p Progress) error {
+	if re.ch == nil {
+		return fmt.Errorf("emitter closed")
+	}
+
+	if p.Total == 0 {
+		p.Total = *re.length
+	}
+
+	// like values, progress ends the header
+	re.emitted = true
+	if re.wait != nil {
+		close(re.wait)
+		re.wait = nil
+	}
+
+	select {
+	case re.ch <- p:
+		return nil
+	case <-re.done:
+		return context.Canceled
+	}
+}
+
+// Warn passes a warning to the response. Like values, it blocks until the
+// response is read.
+func (re *chanResponseEmitter) Warn(msg string) error {
+	return re.Emit(Warning{msg})
+}
+
+// forwardTo makes the response pass the progress and warnings that Next
+// consumes on to next.
+func (re *chanResponseEmitter) forwardTo(next ResponseEmitter) {
+	re.res.mu.Lock()
+	defer re.res.mu.Unlock()
+	re.res.forward = next
+}
+
 func (re *chanResponseEmitter) SetLength(l uint64) {
 	// don't change value after emitting
 	if re.emitted {
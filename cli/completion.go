@@ -0,0 +1,650 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	"github.com/ipfs/go-ipfs-cmds"
+)
+
+// Shells lists the shells Completion can write scripts for.
+var Shells = []string{"bash", "zsh", "fish"}
+
+// CompleteCmd is the hidden first argument with which the completion scripts
+// call the program to get dynamic completions from the CompleteFuncs of the
+// commands. The remaining arguments are the words on the command line, the
+// last one being the word to complete.
+const CompleteCmd = "__complete"
+
+// completionPath holds what can be completed after the words making up path.
+type completionPath struct {
+	path        string
+	subcommands []string
+	flags       []string
+	valueFlags  []string
+	values      map[string][]string
+	files       bool
+	dynamic     bool
+}
+
+// completionChild maps a subcommand name or alias below parent to the path
+// of the subcommand.
+type completionChild struct {
+	parent, name, path string
+}
+
+type completionSpec struct {
+	paths    []completionPath
+	children []completionChild
+}
+
+// newCompletionSpec collects the subcommands, options and option values of
+// all commands of root.
+func newCompletionSpec(root *cmds.Command) (*completionSpec, error) {
+	spec := &completionSpec{}
+
+	for _, info := range cmds.ExportCommands(root) {
+		cmdsAtPath, err := root.Resolve(info.Path)
+		if err != nil {
+			return nil, err
+		}
+		cmd := cmdsAtPath[len(cmdsAtPath)-1]
+
+		optDefs, err := root.GetOptions(info.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		// values declared further down the path win
+		optValues := make(map[string][]string)
+		if optDef, ok := optDefs[cmds.EncLong]; ok {
+			var encs []string
+			for enc := range cmds.Encoders {
+				encs = append(encs, string(enc))
+			}
+			sort.Strings(encs)
+			optValues[optDef.Name()] = encs
+		}
+		for _, c := range cmdsAtPath {
+			for name, values := range c.OptionValues {
+				if optDef, ok := optDefs[name]; ok {
+					optValues[optDef.Name()] = values
+				}
+			}
+		}
+
+		cp := completionPath{
+			path:   strings.Join(info.Path, " "),
+			values: make(map[string][]string),
+		}
+
+		for name, optDef := range optDefs {
+			flag := optionFlag(name)
+			cp.flags = append(cp.flags, flag)
+
+			if optDef.Type() == cmdkit.Bool {
+				cp.values[flag] = []string{"true", "false"}
+				continue
+			}
+
+			cp.valueFlags = append(cp.valueFlags, flag)
+			if values, ok := optValues[optDef.Name()]; ok {
+				cp.values[flag] = values
+			}
+		}
+		sort.Strings(cp.flags)
+		sort.Strings(cp.valueFlags)
+
+		for _, arg := range cmd.Arguments {
+			if arg.Type == cmdkit.ArgFile {
+				cp.files = true
+			}
+		}
+
+		cp.dynamic = cmd.Complete != nil || len(cmd.ArgumentCompletions) > 0
+		for _, c := range cmdsAtPath {
+			cp.dynamic = cp.dynamic || len(c.OptionCompletions) > 0
+		}
+
+		for _, name := range info.Subcommands {
+			sub := cmd.Subcommands[name]
+			if !sub.Hidden {
+				cp.subcommands = append(cp.subcommands, name)
+			}
+
+			child := strings.Join(append(info.Path[:len(info.Path):len(info.Path)], name), " ")
+			for _, n := range append([]string{name}, sub.Aliases...) {
+				spec.children = append(spec.children, completionChild{cp.path, n, child})
+			}
+		}
+
+		spec.paths = append(spec.paths, cp)
+	}
+
+	return spec, nil
+}
+
+// Completion writes a script that sets up tab completion for the command tree
+// of root, called rootName, in the given shell. It completes subcommand
+// names, option names, values of bool options and options with OptionValues,
+// and file names for commands with file arguments. Values with a
+// CompleteFunc are completed by calling the program with CompleteCmd, which
+// Run handles.
+func Completion(shell, rootName string, root *cmds.Command, out io.Writer) error {
+	spec, err := newCompletionSpec(root)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch shell {
+	case "bash":
+		writeBashCompletion(&buf, rootName, spec)
+	case "zsh":
+		writeZshCompletion(&buf, rootName, spec)
+	case "fish":
+		writeFishCompletion(&buf, rootName, spec)
+	default:
+		return fmt.Errorf("unsupported shell %q, use one of %s", shell, strings.Join(Shells, ", "))
+	}
+
+	_, err = out.Write(buf.Bytes())
+	return err
+}
+
+// CompletionCommand returns a hidden command that prints the completion
+// script for the shell given as argument, e.g. to be added to root as
+// "completion" and used with `source <(tool completion bash)`.
+func CompletionCommand(rootName string, root *cmds.Command) *cmds.Command {
+	return &cmds.Command{
+		Hidden: true,
+		Helptext: cmdkit.HelpText{
+			Tagline: "Print a shell completion script.",
+			ShortDescription: `
+Prints a script that sets up tab completion for this tool in the given shell.
+Load it in the current shell with e.g.
+
+  source <(` + rootName + ` completion bash)
+`,
+		},
+		Arguments: []cmdkit.Argument{
+			cmdkit.StringArg("shell", true, false, "The shell to complete in: "+strings.Join(Shells, ", ")+"."),
+		},
+		ArgumentValidators: map[string][]cmds.Validator{
+			"shell": {cmds.OneOf(Shells...)},
+		},
+		Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+			var buf bytes.Buffer
+			err := Completion(req.Arguments[0], rootName, root, &buf)
+			if err != nil {
+				re.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+
+			re.Emit(&buf)
+		},
+	}
+}
+
+// complete prints the dynamic completions for the last of words, one per
+// line. The other words are parsed as a request for the CompleteFunc; if they
+// don't parse, or there is no CompleteFunc for the word, nothing is printed.
+func complete(ctx context.Context, root *cmds.Command, words []string, stdout io.Writer, buildEnv cmds.MakeEnvironment) error {
+	if len(words) == 0 {
+		return nil
+	}
+	cur := words[len(words)-1]
+	words = words[:len(words)-1]
+
+	// an option missing its value doesn't parse, it is the one to complete
+	var flag string
+	req := &cmds.Request{Context: ctx}
+	if err := parse(req, words, root); err != nil {
+		if len(words) == 0 || !strings.HasPrefix(words[len(words)-1], "-") {
+			return nil
+		}
+		flag = words[len(words)-1]
+
+		req = &cmds.Request{Context: ctx}
+		if err := parse(req, words[:len(words)-1], root); err != nil {
+			return nil
+		}
+	}
+
+	fn, prefix, err := completeFunc(req, flag, cur)
+	if err != nil || fn == nil {
+		return err
+	}
+
+	// defaults are only a convenience for the CompleteFunc here
+	_ = req.FillDefaults()
+
+	env, err := buildEnv(req.Context, req)
+	if err != nil {
+		return err
+	}
+	if c, ok := env.(Closer); ok {
+		defer c.Close()
+	}
+
+	candidates, err := fn(req, env, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range candidates {
+		if !strings.HasPrefix(c, prefix) {
+			continue
+		}
+		if _, err := fmt.Fprintln(stdout, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeFunc returns the CompleteFunc for cur and the prefix it completes.
+// If flag is set, cur is the value of that option.
+func completeFunc(req *cmds.Request, flag, cur string) (cmds.CompleteFunc, string, error) {
+	optDefs, err := req.Root.GetOptions(req.Path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// the value of an option, as in --name=value or --name value
+	var optName, prefix string
+	switch {
+	case strings.HasPrefix(cur, "-"):
+		i := strings.Index(cur, "=")
+		if i < 0 {
+			return nil, "", nil
+		}
+		optName, prefix = strings.TrimLeft(cur[:i], "-"), cur[i+1:]
+	case flag != "":
+		optName, prefix = strings.TrimLeft(flag, "-"), cur
+	}
+
+	if optName != "" {
+		optDef, ok := optDefs[optName]
+		if !ok {
+			return nil, "", nil
+		}
+
+		cmdsAtPath, err := req.Root.Resolve(req.Path)
+		if err != nil {
+			return nil, "", err
+		}
+
+		// completions declared further down the path win
+		for i := len(cmdsAtPath) - 1; i >= 0; i-- {
+			for _, name := range optDef.Names() {
+				if fn, ok := cmdsAtPath[i].OptionCompletions[name]; ok {
+					return fn, prefix, nil
+				}
+			}
+		}
+		return nil, "", nil
+	}
+
+	argDefs := req.Command.Arguments
+	if len(argDefs) == 0 {
+		return nil, "", nil
+	}
+
+	i := len(req.Arguments)
+	if i >= len(argDefs) {
+		if !argDefs[len(argDefs)-1].Variadic {
+			return nil, "", nil
+		}
+		i = len(argDefs) - 1
+	}
+
+	if fn, ok := req.Command.ArgumentCompletions[argDefs[i].Name]; ok {
+		return fn, cur, nil
+	}
+	return req.Command.Complete, cur, nil
+}
+
+// completionFuncName returns a prefix for the names of shell functions that
+// is unique to rootName.
+func completionFuncName(rootName string) string {
+	return "__" + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, rootName)
+}
+
+// shQuote quotes s for POSIX shells.
+func shQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// writeShHelpers writes the functions shared by the bash and zsh scripts,
+// which look up the completions for a path in case statements.
+func writeShHelpers(w io.Writer, fn string, spec *completionSpec) {
+	caseFunc := func(name, subject string, entries map[string]string) {
+		keys := make([]string, 0, len(entries))
+		for k := range entries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(w, "%s_%s() {\n\tcase %s in\n", fn, name, subject)
+		for _, k := range keys {
+			fmt.Fprintf(w, "\t\t%s) echo %s ;;\n", shQuote(k), shQuote(entries[k]))
+		}
+		fmt.Fprintf(w, "\tesac\n}\n\n")
+	}
+
+	children := make(map[string]string)
+	for _, c := range spec.children {
+		children[c.parent+"/"+c.name] = c.path
+	}
+	caseFunc("child", `"$1/$2"`, children)
+
+	subcommands := make(map[string]string)
+	flags := make(map[string]string)
+	valueFlags := make(map[string]string)
+	values := make(map[string]string)
+	for _, p := range spec.paths {
+		subcommands[p.path] = strings.Join(p.subcommands, " ")
+		flags[p.path] = strings.Join(p.flags, " ")
+		valueFlags[p.path] = strings.Join(p.valueFlags, " ")
+		for flag, vs := range p.values {
+			values[p.path+"/"+flag] = strings.Join(vs, " ")
+		}
+	}
+	caseFunc("subcommands", `"$1"`, subcommands)
+	caseFunc("flags", `"$1"`, flags)
+	caseFunc("value_flags", `"$1"`, valueFlags)
+	caseFunc("values", `"$1/$2"`, values)
+
+	fmt.Fprintf(w, "%s_files() {\n\tcase \"$1\" in\n", fn)
+	for _, p := range spec.paths {
+		if p.files {
+			fmt.Fprintf(w, "\t\t%s) return 0 ;;\n", shQuote(p.path))
+		}
+	}
+	fmt.Fprintf(w, "\tesac\n\treturn 1\n}\n\n")
+
+	fmt.Fprintf(w, "%s_dynamic() {\n\tcase \"$1\" in\n", fn)
+	for _, p := range spec.paths {
+		if p.dynamic {
+			fmt.Fprintf(w, "\t\t%s) return 0 ;;\n", shQuote(p.path))
+		}
+	}
+	fmt.Fprintf(w, "\tesac\n\treturn 1\n}\n\n")
+
+	fmt.Fprintf(w, `# prints the command path made up by the given words
+%[1]s_path() {
+	local p="" w next skip=0
+	for w in "$@"; do
+		if [ "$skip" = 1 ]; then
+			skip=0
+			continue
+		fi
+		case "$w" in
+			-*=*) continue ;;
+			-*)
+				case " $(%[1]s_value_flags "$p") " in
+					*" $w "*) skip=1 ;;
+				esac
+				continue ;;
+		esac
+		next="$(%[1]s_child "$p" "$w")"
+		[ -z "$next" ] && break
+		p="$next"
+	done
+	echo "$p"
+}
+
+`, fn)
+}
+
+func writeBashCompletion(w io.Writer, rootName string, spec *completionSpec) {
+	fn := completionFuncName(rootName)
+
+	fmt.Fprintf(w, "# bash completion for %s, generated by go-ipfs-cmds\n\n", rootName)
+	writeShHelpers(w, fn, spec)
+	fmt.Fprintf(w, `%[1]s_complete() {
+	local line="${COMP_LINE:0:COMP_POINT}"
+	local -a words
+	read -ra words <<< "$line"
+
+	local cur=""
+	if [[ "$line" != *[[:space:]] ]] && [ "${#words[@]}" -gt 1 ]; then
+		cur="${words[${#words[@]}-1]}"
+		unset 'words[${#words[@]}-1]'
+	fi
+
+	local p prev="${words[${#words[@]}-1]}" dynamic=""
+	p="$(%[1]s_path "${words[@]:1}")"
+	if [[ "$cur" != -* || "$cur" == -*=* ]] && %[1]s_dynamic "$p"; then
+		dynamic="$("${words[0]}" %[3]s "${words[@]:1}" "$cur" 2>/dev/null)"
+	fi
+
+	COMPREPLY=()
+	case "$cur" in
+		-*=*)
+			COMPREPLY=($(compgen -W "$(%[1]s_values "$p" "${cur%%%%=*}") $dynamic" -- "${cur#*=}"))
+			return ;;
+		-*)
+			COMPREPLY=($(compgen -W "$(%[1]s_flags "$p")" -- "$cur"))
+			return ;;
+	esac
+
+	case " $(%[1]s_value_flags "$p") " in
+		*" $prev "*)
+			COMPREPLY=($(compgen -W "$(%[1]s_values "$p" "$prev") $dynamic" -- "$cur"))
+			return ;;
+	esac
+
+	COMPREPLY=($(compgen -W "$(%[1]s_subcommands "$p") $dynamic" -- "$cur"))
+	if %[1]s_files "$p"; then
+		COMPREPLY+=($(compgen -f -- "$cur"))
+	fi
+}
+
+complete -o filenames -F %[1]s_complete %[2]s
+`, fn, shQuote(rootName), CompleteCmd)
+}
+
+func writeZshCompletion(w io.Writer, rootName string, spec *completionSpec) {
+	fn := completionFuncName(rootName)
+
+	fmt.Fprintf(w, "#compdef %s\n\n# zsh completion for %s, generated by go-ipfs-cmds\n\n", rootName, rootName)
+	writeShHelpers(w, fn, spec)
+	fmt.Fprintf(w, `%[1]s_complete() {
+	local cur="${words[CURRENT]}" prev="${words[CURRENT-1]}" p
+	local -a dynamic
+	p="$(%[1]s_path "${(@)words[2,CURRENT-1]}")"
+	if [[ "$cur" != -* || "$cur" == -*=* ]] && %[1]s_dynamic "$p"; then
+		dynamic=(${(f)"$("${words[1]}" %[3]s "${(@)words[2,CURRENT-1]}" "$cur" 2>/dev/null)"})
+	fi
+
+	case "$cur" in
+		-*=*)
+			local flag="${cur%%%%=*}"
+			compset -P '*='
+			compadd -- ${=$(%[1]s_values "$p" "$flag")} $dynamic
+			return ;;
+		-*)
+			compadd -- ${=$(%[1]s_flags "$p")}
+			return ;;
+	esac
+
+	if [[ " $(%[1]s_value_flags "$p") " == *" $prev "* ]]; then
+		compadd -- ${=$(%[1]s_values "$p" "$prev")} $dynamic
+		return
+	fi
+
+	compadd -- ${=$(%[1]s_subcommands "$p")} $dynamic
+	if %[1]s_files "$p"; then
+		_files
+	fi
+}
+
+compdef %[1]s_complete %[2]s
+`, fn, shQuote(rootName), CompleteCmd)
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
+
+func writeFishCompletion(w io.Writer, rootName string, spec *completionSpec) {
+	fn := completionFuncName(rootName)
+
+	fmt.Fprintf(w, "# fish completion for %s, generated by go-ipfs-cmds\n\n", rootName)
+
+	switchFunc := func(name, subject string, entries map[string][]string) {
+		keys := make([]string, 0, len(entries))
+		for k := range entries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(w, "function %s_%s\n\tswitch %s\n", fn, name, subject)
+		for _, k := range keys {
+			if len(entries[k]) == 0 {
+				continue
+			}
+			quoted := make([]string, len(entries[k]))
+			for i, e := range entries[k] {
+				quoted[i] = fishQuote(e)
+			}
+			fmt.Fprintf(w, "\t\tcase %s\n\t\t\tprintf '%%s\\n' %s\n", fishQuote(k), strings.Join(quoted, " "))
+		}
+		fmt.Fprintf(w, "\tend\nend\n\n")
+	}
+
+	children := make(map[string][]string)
+	for _, c := range spec.children {
+		children[c.parent+"/"+c.name] = []string{c.path}
+	}
+	switchFunc("child", `"$argv[1]/$argv[2]"`, children)
+
+	subcommands := make(map[string][]string)
+	flags := make(map[string][]string)
+	valueFlags := make(map[string][]string)
+	values := make(map[string][]string)
+	for _, p := range spec.paths {
+		subcommands[p.path] = p.subcommands
+		flags[p.path] = p.flags
+		valueFlags[p.path] = p.valueFlags
+		for flag, vs := range p.values {
+			values[p.path+"/"+flag] = vs
+		}
+	}
+	switchFunc("subcommands", `"$argv[1]"`, subcommands)
+	switchFunc("flags", `"$argv[1]"`, flags)
+	switchFunc("value_flags", `"$argv[1]"`, valueFlags)
+	switchFunc("values", `"$argv[1]/$argv[2]"`, values)
+
+	var filePaths []string
+	for _, p := range spec.paths {
+		if p.files {
+			filePaths = append(filePaths, fishQuote(p.path))
+		}
+	}
+	fmt.Fprintf(w, "function %s_files\n", fn)
+	if len(filePaths) > 0 {
+		fmt.Fprintf(w, "\tcontains -- \"$argv[1]\" %s\n", strings.Join(filePaths, " "))
+	} else {
+		fmt.Fprintf(w, "\treturn 1\n")
+	}
+	fmt.Fprintf(w, "end\n\n")
+
+	var dynamicPaths []string
+	for _, p := range spec.paths {
+		if p.dynamic {
+			dynamicPaths = append(dynamicPaths, fishQuote(p.path))
+		}
+	}
+	fmt.Fprintf(w, "function %s_dynamic\n", fn)
+	if len(dynamicPaths) > 0 {
+		fmt.Fprintf(w, "\tcontains -- \"$argv[1]\" %s\n", strings.Join(dynamicPaths, " "))
+	} else {
+		fmt.Fprintf(w, "\treturn 1\n")
+	}
+	fmt.Fprintf(w, "end\n\n")
+
+	fmt.Fprintf(w, `function %[1]s_path
+	set -l words (commandline -opc)
+	set -e words[1]
+	set -l p ''
+	set -l skip 0
+	for w in $words
+		if test $skip = 1
+			set skip 0
+			continue
+		end
+		switch $w
+			case '-*=*'
+				continue
+			case '-*'
+				if contains -- $w (%[1]s_value_flags "$p")
+					set skip 1
+				end
+				continue
+		end
+		set -l next (%[1]s_child "$p" $w)
+		if test -z "$next"
+			break
+		end
+		set p $next
+	end
+	echo $p
+end
+
+function %[1]s_complete
+	set -l p (%[1]s_path)
+	set -l cur (commandline -ct)
+	set -l words (commandline -opc)
+	set -l prev $words[-1]
+
+	set -l dynamic
+	if begin; not string match -q -- '-*' $cur; or string match -q -- '-*=*' $cur; end; and %[1]s_dynamic "$p"
+		set -l prog $words[1]
+		set -e words[1]
+		set dynamic ($prog %[3]s $words $cur 2>/dev/null)
+	end
+
+	switch $cur
+		case '-*=*'
+			set -l flag (string split -m 1 = -- $cur)[1]
+			for v in (%[1]s_values "$p" $flag) $dynamic
+				echo "$flag=$v"
+			end
+			return
+		case '-*'
+			%[1]s_flags "$p"
+			return
+	end
+
+	if contains -- $prev (%[1]s_value_flags "$p")
+		%[1]s_values "$p" $prev
+		printf '%%s\n' $dynamic
+		return
+	end
+
+	%[1]s_subcommands "$p"
+	printf '%%s\n' $dynamic
+	if %[1]s_files "$p"
+		__fish_complete_path $cur
+	end
+end
+
+complete -c %[2]s -f -a '(%[1]s_complete)'
+`, fn, fishQuote(rootName), CompleteCmd)
+}
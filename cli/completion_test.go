@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	"github.com/ipfs/go-ipfs-cmds"
+)
+
+type completionEnv struct {
+	ctx    context.Context
+	prefix string
+}
+
+func (env *completionEnv) Context() context.Context {
+	return env.ctx
+}
+
+func completionTestRoot() *cmds.Command {
+	run := func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {}
+	root := &cmds.Command{
+		Options: []cmdkit.Option{
+			cmds.OptionEncodingType,
+			cmds.OptionTimeout,
+		},
+		Subcommands: map[string]*cmds.Command{
+			"block": &cmds.Command{
+				Subcommands: map[string]*cmds.Command{
+					"put": &cmds.Command{
+						Aliases: []string{"store"},
+						Arguments: []cmdkit.Argument{
+							cmdkit.FileArg("data", true, false, "The data."),
+						},
+						Options: []cmdkit.Option{
+							cmdkit.BoolOption("pin", "p", "Pin the block."),
+							cmdkit.StringOption("format", "f", "The format."),
+						},
+						OptionValues: map[string][]string{
+							"format": {"raw", "cbor"},
+						},
+						Run: run,
+					},
+					"get": &cmds.Command{Run: run},
+					"rm": &cmds.Command{
+						Arguments: []cmdkit.Argument{
+							cmdkit.StringArg("key", true, true, "The keys."),
+						},
+						Options: []cmdkit.Option{
+							cmdkit.StringOption("from", "The store."),
+						},
+						ArgumentCompletions: map[string]cmds.CompleteFunc{
+							"key": func(req *cmds.Request, env cmds.Environment, prefix string) ([]string, error) {
+								from, _ := req.Options["from"].(string)
+								pre := env.(*completionEnv).prefix + from
+								return []string{pre + "1", pre + "2", "other"}, nil
+							},
+						},
+						OptionCompletions: map[string]cmds.CompleteFunc{
+							"from": func(req *cmds.Request, env cmds.Environment, prefix string) ([]string, error) {
+								return []string{"disk", "mem"}, nil
+							},
+						},
+						Run: run,
+					},
+				},
+			},
+		},
+	}
+	root.Subcommands["completion"] = CompletionCommand("tool", root)
+	return root
+}
+
+func TestCompletionScripts(t *testing.T) {
+	root := completionTestRoot()
+
+	expected := map[string][]string{
+		"bash": {
+			"complete -o filenames -F __tool_complete 'tool'",
+			`'block/store') echo 'block put' ;;`,
+			`'') echo 'block' ;;`,
+			`'block put/--format') echo 'raw cbor' ;;`,
+			`'block put/-p') echo 'true false' ;;`,
+			`'/--encoding') echo 'cbor json protobuf text textnl xml' ;;`,
+			`'block put') return 0 ;;`,
+			`'block rm') return 0 ;;`,
+		},
+		"zsh": {
+			"#compdef tool",
+			"compdef __tool_complete 'tool'",
+			`'block put/--format') echo 'raw cbor' ;;`,
+		},
+		"fish": {
+			"complete -c 'tool' -f -a '(__tool_complete)'",
+			"case 'block/store'\n\t\t\tprintf '%s\\n' 'block put'",
+			"case 'block put/--format'\n\t\t\tprintf '%s\\n' 'raw' 'cbor'",
+			`contains -- "$argv[1]" 'block put'`,
+			`contains -- "$argv[1]" 'block rm'`,
+		},
+	}
+
+	for shell, exps := range expected {
+		var buf bytes.Buffer
+		if err := Completion(shell, "tool", root, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, exp := range exps {
+			if !strings.Contains(buf.String(), exp) {
+				t.Errorf("%s script does not contain %q:\n%s", shell, exp, buf.String())
+			}
+		}
+		if strings.Contains(buf.String(), "'completion'") && !strings.Contains(buf.String(), "'/completion'") {
+			t.Errorf("%s script should not suggest the hidden completion command", shell)
+		}
+	}
+
+	var help bytes.Buffer
+	if err := LongHelp("tool", root, nil, &help); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(help.String(), "completion") {
+		t.Errorf("help should not list the hidden completion command:\n%s", help.String())
+	}
+
+	if errs := root.DebugValidate(); errs != nil {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+
+	if err := Completion("tcsh", "tool", root, &bytes.Buffer{}); err == nil {
+		t.Error("expected error for unsupported shell")
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+
+	var script bytes.Buffer
+	if err := Completion("bash", "tool", completionTestRoot(), &script); err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := map[string]string{
+		"tool ":                          "block",
+		"tool block ":                    "get put rm",
+		"tool block store --f":           "--format",
+		"tool block put --format ":       "raw cbor",
+		"tool block put --format=c":      "cbor",
+		"tool --enc json block put -p=t": "true",
+		"tool --timeout 1s bl":           "block",
+		"tool block rm --from ":          "dyn",
+		"tool block rm --from=d":         "dyn",
+		"tool block rm --f":              "--from",
+		"tool block get ":                "",
+	}
+
+	for line, exp := range tcs {
+		script := script.String() + `
+tool() { [ "$1" = __complete ] && echo dyn; }
+COMP_LINE="$1"
+COMP_POINT=${#COMP_LINE}
+__tool_complete
+echo "${COMPREPLY[*]}"
+`
+		out, err := exec.Command(bash, "-c", script, "bash", line).Output()
+		if err != nil {
+			t.Fatalf("%q: %s", line, err)
+		}
+		if got := strings.TrimSpace(string(out)); got != exp {
+			t.Errorf("completing %q: expected %q, got %q", line, exp, got)
+		}
+	}
+}
+
+func TestDynamicCompletion(t *testing.T) {
+	root := completionTestRoot()
+	buildEnv := func(ctx context.Context, req *cmds.Request) (cmds.Environment, error) {
+		return &completionEnv{ctx, "key"}, nil
+	}
+
+	tcs := []struct {
+		words []string
+		exp   string
+	}{
+		{[]string{"block", "rm", ""}, "key1\nkey2\nother\n"},
+		{[]string{"block", "rm", "k"}, "key1\nkey2\n"},
+		{[]string{"block", "rm", "key1", "key2", "o"}, "other\n"},
+		{[]string{"block", "rm", "--from", "disk", "k"}, "keydisk1\nkeydisk2\n"},
+		{[]string{"block", "rm", "--from", "m"}, "mem\n"},
+		{[]string{"block", "rm", "--from="}, "disk\nmem\n"},
+		{[]string{"block", "rm", "--f"}, ""},
+		{[]string{"block", "get", ""}, ""},
+		{[]string{"nope", ""}, ""},
+	}
+
+	for _, tc := range tcs {
+		var buf bytes.Buffer
+		if err := complete(context.Background(), root, tc.words, &buf, buildEnv); err != nil {
+			t.Fatalf("%q: %s", tc.words, err)
+		}
+		if buf.String() != tc.exp {
+			t.Errorf("completing %q: expected %q, got %q", tc.words, tc.exp, buf.String())
+		}
+	}
+
+	root.Subcommands["block"].Subcommands["rm"].ArgumentCompletions["nope"] = nil
+	root.Subcommands["block"].Subcommands["rm"].OptionCompletions["nope"] = nil
+	errs := root.DebugValidate()["/block/rm"]
+	if len(errs) != 2 {
+		t.Errorf("expected errors for the unknown argument and option, got %v", errs)
+	}
+}
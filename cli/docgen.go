@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-cmds"
+)
+
+// ManPage writes a roff man page in section 1 for the command at path. The
+// sections contain the same text as the long help of the command.
+func ManPage(rootName string, root *cmds.Command, path []string, out io.Writer) error {
+	fields, cmd, err := longHelpFields(rootName, root, path)
+	if err != nil {
+		return err
+	}
+
+	usage, err := usageString(fields)
+	if err != nil {
+		return err
+	}
+
+	name := docName(rootName, path, "-")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, ".TH %q \"1\" \"\" %q %q\n", strings.ToUpper(name), rootName, rootName+" Manual")
+	fmt.Fprintf(&buf, ".SH NAME\n%s \\- %s\n", name, roffEscape(fields.Tagline))
+
+	section := func(title, text string) {
+		if text == "" {
+			return
+		}
+		fmt.Fprintf(&buf, ".SH %s\n.nf\n%s\n.fi\n", title, roffEscape(text))
+	}
+
+	section("USAGE", usage)
+	section("DEPRECATED", fields.Deprecated)
+	section("SYNOPSIS", fields.Synopsis)
+	section("ALIASES", fields.Aliases)
+	section("ARGUMENTS", fields.Arguments)
+	section("OPTIONS", fields.Options)
+	section("DESCRIPTION", fields.Description)
+	section("SUBCOMMANDS", fields.Subcommands)
+
+	var seeAlso []string
+	for _, p := range relatedPaths(cmd, path) {
+		seeAlso = append(seeAlso, fmt.Sprintf("\\fB%s\\fP(1)", docName(rootName, p, "-")))
+	}
+	if len(seeAlso) > 0 {
+		fmt.Fprintf(&buf, ".SH SEE ALSO\n%s\n", strings.Join(seeAlso, ", "))
+	}
+
+	_, err = out.Write(buf.Bytes())
+	return err
+}
+
+// MarkdownPage writes a Markdown reference page for the command at path. The
+// sections contain the same text as the long help of the command.
+func MarkdownPage(rootName string, root *cmds.Command, path []string, out io.Writer) error {
+	fields, cmd, err := longHelpFields(rootName, root, path)
+	if err != nil {
+		return err
+	}
+
+	usage, err := usageString(fields)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", fields.Path)
+	if fields.Tagline != "" {
+		fmt.Fprintf(&buf, "%s\n\n", fields.Tagline)
+	}
+
+	section := func(title, text string) {
+		if text == "" {
+			return
+		}
+		fmt.Fprintf(&buf, "## %s\n\n```text\n%s\n```\n\n", title, text)
+	}
+
+	section("Usage", usage)
+	section("Deprecated", fields.Deprecated)
+	section("Synopsis", fields.Synopsis)
+	section("Aliases", fields.Aliases)
+	section("Arguments", fields.Arguments)
+	section("Options", fields.Options)
+	section("Description", fields.Description)
+	section("Subcommands", fields.Subcommands)
+
+	if related := relatedPaths(cmd, path); len(related) > 0 {
+		fmt.Fprintf(&buf, "## See also\n\n")
+		for _, p := range related {
+			fmt.Fprintf(&buf, "- [%s](%s)\n", docName(rootName, p, " "), docName(rootName, p, "_")+".md")
+		}
+		fmt.Fprintf(&buf, "\n")
+	}
+
+	_, err = out.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, "\n")
+	return err
+}
+
+// GenManPages writes the man pages of root and all of its subcommands to dir,
+// in files named after the command path, e.g. ipfs-block-put.1.
+func GenManPages(rootName string, root *cmds.Command, dir string) error {
+	return genDocs(rootName, root, dir, func(path []string) string {
+		return docName(rootName, path, "-") + ".1"
+	}, ManPage)
+}
+
+// GenMarkdownPages writes the Markdown reference pages of root and all of its
+// subcommands to dir, in files named after the command path, e.g.
+// ipfs_block_put.md.
+func GenMarkdownPages(rootName string, root *cmds.Command, dir string) error {
+	return genDocs(rootName, root, dir, func(path []string) string {
+		return docName(rootName, path, "_") + ".md"
+	}, MarkdownPage)
+}
+
+func genDocs(rootName string, root *cmds.Command, dir string, filename func([]string) string, gen func(string, *cmds.Command, []string, io.Writer) error) error {
+	for _, info := range cmds.ExportCommands(root) {
+		if info.Hidden {
+			continue
+		}
+
+		f, err := os.Create(filepath.Join(dir, filename(info.Path)))
+		if err != nil {
+			return err
+		}
+
+		err = gen(rootName, root, info.Path, f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// usageString returns the usage line as printed in the help text.
+func usageString(fields *helpFields) (string, error) {
+	var buf bytes.Buffer
+	err := usageTemplate.Execute(&buf, fields)
+	return buf.String(), err
+}
+
+// docName returns the name of the command at path joined by sep.
+func docName(rootName string, path []string, sep string) string {
+	return strings.Join(append([]string{rootName}, path...), sep)
+}
+
+// relatedPaths returns the paths of the parent and the visible subcommands of
+// cmd.
+func relatedPaths(cmd *cmds.Command, path []string) [][]string {
+	var paths [][]string
+	if len(path) > 0 {
+		paths = append(paths, path[:len(path)-1])
+	}
+
+	names := make([]string, 0, len(cmd.Subcommands))
+	for name, sub := range cmd.Subcommands {
+		if !sub.Hidden {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		paths = append(paths, append(path[:len(path):len(path)], name))
+	}
+	return paths
+}
+
+// roffEscape escapes text so it is printed verbatim by roff.
+func roffEscape(text string) string {
+	text = strings.Replace(text, `\`, `\e`, -1)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	"github.com/ipfs/go-ipfs-cmds"
+)
+
+func docTestRoot() *cmds.Command {
+	return &cmds.Command{
+		Helptext: cmdkit.HelpText{Tagline: "The test tool."},
+		Subcommands: map[string]*cmds.Command{
+			"block": &cmds.Command{
+				Helptext: cmdkit.HelpText{
+					Tagline:          "Manage blocks.",
+					ShortDescription: ".starts with a dot and has a \\ backslash",
+				},
+				Subcommands: map[string]*cmds.Command{
+					"put": &cmds.Command{
+						Helptext: cmdkit.HelpText{Tagline: "Store a block."},
+						Arguments: []cmdkit.Argument{
+							cmdkit.StringArg("key", true, false, "The key."),
+						},
+						Options: []cmdkit.Option{
+							cmdkit.BoolOption("pin", "p", "Pin the block."),
+						},
+						Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestManPage(t *testing.T) {
+	root := docTestRoot()
+	path := []string{"block", "put"}
+
+	var buf bytes.Buffer
+	if err := ManPage("tool", root, path, &buf); err != nil {
+		t.Fatal(err)
+	}
+	man := buf.String()
+
+	expected := []string{
+		".TH \"TOOL-BLOCK-PUT\" \"1\"",
+		"tool-block-put \\- Store a block.",
+		".SH SYNOPSIS\n.nf\n" + generateSynopsis(root.Subcommands["block"].Subcommands["put"], "tool block put") + "\n.fi",
+		".SH OPTIONS\n.nf\n" + strings.Join(optionText(root.Subcommands["block"].Subcommands["put"]), "\n") + "\n.fi",
+		".SH ARGUMENTS\n.nf\n" + strings.Join(argumentText(root.Subcommands["block"].Subcommands["put"]), "\n") + "\n.fi",
+		".SH SEE ALSO\n\\fBtool-block\\fP(1)",
+	}
+	for _, exp := range expected {
+		if !strings.Contains(man, exp) {
+			t.Errorf("man page does not contain %q:\n%s", exp, man)
+		}
+	}
+
+	buf.Reset()
+	if err := ManPage("tool", root, []string{"block"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\\&.starts with a dot and has a \\e backslash") {
+		t.Errorf("description is not escaped:\n%s", buf.String())
+	}
+}
+
+func TestMarkdownPage(t *testing.T) {
+	root := docTestRoot()
+
+	var buf bytes.Buffer
+	if err := MarkdownPage("tool", root, []string{"block"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	md := buf.String()
+
+	subcmds := strings.Join(subcommandText(root.Subcommands["block"], "tool", []string{"block"}), "\n")
+	expected := []string{
+		"# tool block\n\nManage blocks.\n",
+		"## Subcommands\n\n```text\n" + subcmds + "\n```",
+		"- [tool](tool.md)\n- [tool block put](tool_block_put.md)\n",
+	}
+	for _, exp := range expected {
+		if !strings.Contains(md, exp) {
+			t.Errorf("markdown page does not contain %q:\n%s", exp, md)
+		}
+	}
+}
+
+func TestGenDocs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cmds-docgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := docTestRoot()
+	if err := GenManPages("tool", root, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenMarkdownPages("tool", root, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"tool.1", "tool-block.1", "tool-block-put.1", "tool.md", "tool_block.md", "tool_block_put.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
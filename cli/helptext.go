@@ -34,6 +34,8 @@ type helpFields struct {
 	Arguments   string
 	Options     string
 	Synopsis    string
+	Aliases     string
+	Deprecated  string
 	Subcommands string
 	Description string
 	MoreHelp    bool
@@ -54,6 +56,8 @@ func (f *helpFields) TrimNewlines() {
 	f.Arguments = strings.Trim(f.Arguments, "\n")
 	f.Options = strings.Trim(f.Options, "\n")
 	f.Synopsis = strings.Trim(f.Synopsis, "\n")
+	f.Aliases = strings.Trim(f.Aliases, "\n")
+	f.Deprecated = strings.Trim(f.Deprecated, "\n")
 	f.Subcommands = strings.Trim(f.Subcommands, "\n")
 	f.Description = strings.Trim(f.Description, "\n")
 }
@@ -70,6 +74,8 @@ func (f *helpFields) IndentAll() {
 	f.Arguments = indent(f.Arguments)
 	f.Options = indent(f.Options)
 	f.Synopsis = indent(f.Synopsis)
+	f.Aliases = indent(f.Aliases)
+	f.Deprecated = indent(f.Deprecated)
 	f.Subcommands = indent(f.Subcommands)
 	f.Description = indent(f.Description)
 }
@@ -79,9 +85,15 @@ const usageFormat = "{{if .Usage}}{{.Usage}}{{else}}{{.Path}}{{if .ArgUsage}} {{
 const longHelpFormat = `USAGE
 {{.Indent}}{{template "usage" .}}
 
-{{if .Synopsis}}SYNOPSIS
+{{if .Deprecated}}DEPRECATED
+{{.Deprecated}}
+
+{{end}}{{if .Synopsis}}SYNOPSIS
 {{.Synopsis}}
 
+{{end}}{{if .Aliases}}ALIASES
+{{.Aliases}}
+
 {{end}}{{if .Arguments}}ARGUMENTS
 
 {{.Arguments}}
@@ -142,17 +154,31 @@ func HandleHelp(appName string, req *cmds.Request, out io.Writer) error {
 
 // LongHelp writes a formatted CLI helptext string to a Writer for the given command
 func LongHelp(rootName string, root *cmds.Command, path []string, out io.Writer) error {
-	cmd, err := root.Get(path)
+	fields, _, err := longHelpFields(rootName, root, path)
 	if err != nil {
 		return err
 	}
 
+	// indent all fields that have been set
+	fields.IndentAll()
+
+	return longHelpTemplate.Execute(out, fields)
+}
+
+// longHelpFields returns the trimmed but not yet indented fields of the long
+// help of the command at path, together with that command.
+func longHelpFields(rootName string, root *cmds.Command, path []string) (*helpFields, *cmds.Command, error) {
+	cmd, err := root.Get(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	pathStr := rootName
 	if len(path) > 0 {
 		pathStr += " " + strings.Join(path, " ")
 	}
 
-	fields := helpFields{
+	fields := &helpFields{
 		Indent:      indentStr,
 		Path:        pathStr,
 		ArgUsage:    usageText(cmd),
@@ -175,7 +201,10 @@ func LongHelp(rootName string, root *cmds.Command, path []string, out io.Writer)
 		fields.Arguments = strings.Join(argumentText(cmd), "\n")
 	}
 	if len(fields.Options) == 0 {
-		fields.Options = strings.Join(optionText(cmd), "\n")
+		fields.Options = strings.Join(envText(root, path, optionText(cmd)), "\n")
+		if groups := optionGroupText(cmd); len(groups) > 0 {
+			fields.Options += "\n\n" + strings.Join(groups, "\n")
+		}
 	}
 	if len(fields.Subcommands) == 0 {
 		fields.Subcommands = strings.Join(subcommandText(cmd, rootName, path), "\n")
@@ -183,14 +212,18 @@ func LongHelp(rootName string, root *cmds.Command, path []string, out io.Writer)
 	if len(fields.Synopsis) == 0 {
 		fields.Synopsis = generateSynopsis(cmd, pathStr)
 	}
+	fields.Aliases = strings.Join(aliasText(cmd, rootName, path), "\n")
+	if cmd.Deprecated != nil {
+		fields.Deprecated = cmd.Deprecated.String()
+		if len(fields.Deprecated) == 0 {
+			fields.Deprecated = "This command is deprecated."
+		}
+	}
 
 	// trim the extra newlines (see TrimNewlines doc)
 	fields.TrimNewlines()
 
-	// indent all fields that have been set
-	fields.IndentAll()
-
-	return longHelpTemplate.Execute(out, fields)
+	return fields, cmd, nil
 }
 
 // ShortHelp writes a formatted CLI helptext string to a Writer for the given command
@@ -222,12 +255,15 @@ func ShortHelp(rootName string, root *cmds.Command, path []string, out io.Writer
 		MoreHelp:    (cmd != root),
 	}
 
+	// deprecated subcommands and options are only listed in the long help
+	visible := withoutDeprecated(cmd)
+
 	// autogen fields that are empty
 	if len(fields.Subcommands) == 0 {
-		fields.Subcommands = strings.Join(subcommandText(cmd, rootName, path), "\n")
+		fields.Subcommands = strings.Join(subcommandText(visible, rootName, path), "\n")
 	}
 	if len(fields.Synopsis) == 0 {
-		fields.Synopsis = generateSynopsis(cmd, pathStr)
+		fields.Synopsis = generateSynopsis(visible, pathStr)
 	}
 
 	// trim the extra newlines (see TrimNewlines doc)
@@ -359,6 +395,9 @@ func optionText(cmd ...*cmds.Command) []string {
 	// add option descriptions to output
 	for i, opt := range options {
 		lines[i] += " - " + opt.Description()
+		if d := cmds.OptionDeprecation(opt); d != nil {
+			lines[i] += " " + deprecationText(d)
+		}
 	}
 
 	return lines
@@ -372,13 +411,15 @@ func subcommandText(cmd *cmds.Command, rootName string, path []string) []string
 
 	// Sorting fixes changing order bug #2981.
 	sortedNames := make([]string, 0)
-	for name := range cmd.Subcommands {
-		sortedNames = append(sortedNames, name)
+	for name, sub := range cmd.Subcommands {
+		if !sub.Hidden {
+			sortedNames = append(sortedNames, name)
+		}
 	}
 	sort.Strings(sortedNames)
 
-	subcmds := make([]*cmds.Command, len(cmd.Subcommands))
-	lines := make([]string, len(cmd.Subcommands))
+	subcmds := make([]*cmds.Command, len(sortedNames))
+	lines := make([]string, len(sortedNames))
 
 	for i, name := range sortedNames {
 		sub := cmd.Subcommands[name]
@@ -394,11 +435,110 @@ func subcommandText(cmd *cmds.Command, rootName string, path []string) []string
 	lines = align(lines)
 	for i, sub := range subcmds {
 		lines[i] += " - " + sub.Helptext.Tagline
+		if len(sub.Aliases) > 0 {
+			lines[i] += fmt.Sprintf(" (aliases: %s)", strings.Join(sub.Aliases, ", "))
+		}
 	}
 
 	return lines
 }
 
+// aliasText returns the alternative invocations of the command at path.
+func aliasText(cmd *cmds.Command, rootName string, path []string) []string {
+	if len(path) == 0 {
+		return nil
+	}
+
+	prefix := rootName
+	if len(path) > 1 {
+		prefix += " " + strings.Join(path[:len(path)-1], " ")
+	}
+
+	lines := make([]string, len(cmd.Aliases))
+	for i, alias := range cmd.Aliases {
+		lines[i] = prefix + " " + alias
+	}
+
+	return lines
+}
+
+// optionGroupText returns the constraints on the options of cmd.
+func optionGroupText(cmd *cmds.Command) []string {
+	lines := make([]string, len(cmd.OptionGroups))
+	for i, g := range cmd.OptionGroups {
+		lines[i] = g.String() + "."
+	}
+	return lines
+}
+
+// envText appends the names of the environment variables the root's
+// EnvSource reads the options of cmd from to their lines in the option text.
+// The options cmd inherits from its parents are not listed, so their
+// variables are named in a line of their own.
+func envText(root *cmds.Command, path []string, lines []string) []string {
+	for _, src := range root.OptionSources {
+		env, ok := src.(*cmds.EnvSource)
+		if !ok {
+			continue
+		}
+
+		chain, err := root.Resolve(path)
+		if err != nil {
+			return lines
+		}
+
+		cmd := chain[len(chain)-1]
+		for i, opt := range cmd.Options {
+			lines[i] += fmt.Sprintf(" Env: $%s.", env.VarName(opt))
+		}
+
+		var inherited []string
+		for _, parent := range chain[:len(chain)-1] {
+			for _, opt := range parent.Options {
+				inherited = append(inherited, "$"+env.VarName(opt))
+			}
+		}
+		if len(inherited) > 0 {
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, "Inherited options are also read from "+strings.Join(inherited, ", ")+".")
+		}
+		break
+	}
+	return lines
+}
+
+// deprecationText returns the help text for a deprecated command or option.
+func deprecationText(d *cmds.Deprecation) string {
+	if s := d.String(); s != "" {
+		return fmt.Sprintf("(DEPRECATED: %s)", s)
+	}
+	return "(DEPRECATED)"
+}
+
+// withoutDeprecated returns a shallow copy of cmd without its deprecated
+// options and subcommands.
+func withoutDeprecated(cmd *cmds.Command) *cmds.Command {
+	out := *cmd
+
+	out.Options = nil
+	for _, opt := range cmd.Options {
+		if cmds.OptionDeprecation(opt) == nil {
+			out.Options = append(out.Options, opt)
+		}
+	}
+
+	out.Subcommands = make(map[string]*cmds.Command, len(cmd.Subcommands))
+	for name, sub := range cmd.Subcommands {
+		if sub.Deprecated == nil {
+			out.Subcommands[name] = sub
+		}
+	}
+
+	return &out
+}
+
 func usageText(cmd *cmds.Command) string {
 	s := ""
 	for i, arg := range cmd.Arguments {
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
@@ -44,3 +45,142 @@ func TestSynopsisGenerator(t *testing.T) {
 		t.Fatal("Synopsis should contain options finalizer")
 	}
 }
+
+func TestAliasHelp(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"new": &cmds.Command{
+				Aliases: []string{"old"},
+				Helptext: cmdkit.HelpText{
+					Tagline: "A renamed command.",
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := ShortHelp("app", root, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "A renamed command. (aliases: old)") {
+		t.Errorf("subcommand list should contain aliases, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := LongHelp("app", root, []string{"new"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "ALIASES\n  app old\n") {
+		t.Errorf("help should contain aliases section, got:\n%s", buf.String())
+	}
+}
+
+func TestDeprecatedHelp(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"new": &cmds.Command{
+				Helptext: cmdkit.HelpText{Tagline: "The new command."},
+				Options: []cmdkit.Option{
+					cmdkit.BoolOption("fast", "Go fast."),
+					cmds.DeprecateOption(cmdkit.BoolOption("quick", "Go quick."), cmds.Deprecation{Replacement: "--fast"}),
+				},
+			},
+			"old": &cmds.Command{
+				Helptext:   cmdkit.HelpText{Tagline: "The old command."},
+				Deprecated: &cmds.Deprecation{Replacement: "new"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := ShortHelp("app", root, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "old") {
+		t.Errorf("short help should not list deprecated commands, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := ShortHelp("app", root, []string{"new"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "quick") {
+		t.Errorf("short help should not list deprecated options, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := LongHelp("app", root, []string{"new"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Go quick. (DEPRECATED: use --fast instead)") {
+		t.Errorf("long help should mark deprecated options, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := LongHelp("app", root, []string{"old"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "DEPRECATED\n  use new instead\n") {
+		t.Errorf("long help should contain deprecation section, got:\n%s", buf.String())
+	}
+}
+
+func TestOptionGroupHelp(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmdkit.Option{
+			cmdkit.BoolOption("verbose", "v", "Be verbose."),
+			cmdkit.BoolOption("quiet", "q", "Be quiet."),
+		},
+		OptionGroups: []cmds.OptionGroup{
+			cmds.Exclusive("verbose", "quiet"),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := LongHelp("app", root, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "--verbose, --quiet are mutually exclusive.") {
+		t.Errorf("long help should contain option constraints, got:\n%s", buf.String())
+	}
+}
+
+func TestEnvHelp(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmdkit.Option{
+			cmdkit.StringOption("api-addr", "Address of the API."),
+		},
+		OptionSources: []cmds.OptionSource{cmds.NewEnvSource("APP_")},
+	}
+
+	var buf bytes.Buffer
+	if err := LongHelp("app", root, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Address of the API. Env: $APP_API_ADDR.") {
+		t.Errorf("long help should contain environment variable names, got:\n%s", buf.String())
+	}
+
+	// the options of the root are inherited by the subcommands
+	root.Options = append(root.Options, cmds.OptionEncodingType, cmds.OptionTimeout)
+	root.Subcommands = map[string]*cmds.Command{
+		"ls": &cmds.Command{
+			Options: []cmdkit.Option{
+				cmdkit.BoolOption("all", "a", "List all."),
+			},
+		},
+	}
+
+	buf.Reset()
+	if err := LongHelp("app", root, []string{"ls"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{
+		"List all. Env: $APP_ALL.",
+		"Inherited options are also read from $APP_API_ADDR, $APP_ENCODING, $APP_TIMEOUT.",
+	} {
+		if !strings.Contains(buf.String(), exp) {
+			t.Errorf("long help should contain %q, got:\n%s", exp, buf.String())
+		}
+	}
+}
@@ -150,10 +150,10 @@ L:
 		default:
 			arg := param
 			// arg is a sub-command or a positional argument
-			sub := cmd.Subcommands[arg]
+			name, sub := cmd.Subcommand(arg)
 			if sub != nil {
 				cmd = sub
-				path = append(path, arg)
+				path = append(path, name)
 				optDefs, err = root.GetOptions(path)
 				if err != nil {
 					return err
@@ -75,7 +75,9 @@ func TestOptionParsing(t *testing.T) {
 			cmdkit.BoolOption("bool", "b", "a bool"),
 		},
 		Subcommands: map[string]*cmds.Command{
-			"test": &cmds.Command{},
+			"test": &cmds.Command{
+				Aliases: []string{"tst"},
+			},
 			"defaults": &cmds.Command{
 				Options: []cmdkit.Option{
 					cmdkit.StringOption("opt", "o", "an option").WithDefault("def"),
@@ -121,6 +123,7 @@ func TestOptionParsing(t *testing.T) {
 	test("-bs foo", kvs{"bool": true, "string": "foo"}, words{})
 	test("-sb", kvs{"string": "b"}, words{})
 	test("-b test foo", kvs{"bool": true}, words{"foo"})
+	test("-b tst foo", kvs{"bool": true}, words{"foo"})
 	test("--bool test foo", kvs{"bool": true}, words{"foo"})
 	testFail("--bool=foo")
 	testFail("--string")
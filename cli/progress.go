@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-cmds"
+)
+
+// progressBarWidth is the number of characters in the bar of a progress line.
+const progressBarWidth = 30
+
+// SetProgress draws a progress bar on stderr if it is a terminal. It is
+// removed before other output is written and kept when the emitter is
+// closed.
+func (re *responseEmitter) SetProgress(p cmds.Progress) error {
+	if !re.tty {
+		return nil
+	}
+
+	re.wLock.Lock()
+	defer re.wLock.Unlock()
+
+	if re.closed {
+		return nil
+	}
+
+	if p.Total == 0 {
+		p.Total = re.length
+	}
+
+	line := formatProgress(p)
+	pad := ""
+	if len(line) < re.barLen {
+		pad = strings.Repeat(" ", re.barLen-len(line))
+	}
+
+	_, err := fmt.Fprint(re.stderr, "\r"+line+pad)
+	re.barLen = len(line)
+	return err
+}
+
+// clearProgress removes the progress bar from the terminal. The lock must be
+// held.
+func (re *responseEmitter) clearProgress() {
+	if re.barLen == 0 {
+		return
+	}
+
+	fmt.Fprint(re.stderr, "\r"+strings.Repeat(" ", re.barLen)+"\r")
+	re.barLen = 0
+}
+
+// formatProgress returns the progress line for p, e.g.
+// "[=======>      ]  50% 5/10 items".
+func formatProgress(p cmds.Progress) string {
+	done, total := formatAmount(p.Done, p.Unit), formatAmount(p.Total, p.Unit)
+	if p.Total == 0 {
+		return done
+	}
+
+	frac := float64(p.Done) / float64(p.Total)
+	if frac > 1 {
+		frac = 1
+	}
+
+	filled := int(frac * progressBarWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+
+	return fmt.Sprintf("[%s] %3d%% %s/%s", bar, int(frac*100), strings.TrimSuffix(done, " "+string(p.Unit)), total)
+}
+
+// formatAmount formats n in unit, using binary prefixes for bytes.
+func formatAmount(n uint64, unit cmds.ProgressUnit) string {
+	if unit != cmds.ProgressBytes {
+		return strings.TrimSpace(fmt.Sprintf("%d %s", n, unit))
+	}
+
+	const prefixes = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	f, i := float64(n)/1024, 0
+	for f >= 1024 && i < len(prefixes)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", f, prefixes[i])
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestFormatProgress(t *testing.T) {
+	tcs := []struct {
+		p   cmds.Progress
+		exp string
+	}{
+		{cmds.Progress{Done: 5, Total: 10, Unit: cmds.ProgressItems}, "[===============>              ]  50% 5/10 items"},
+		{cmds.Progress{Done: 10, Total: 10}, "[==============================] 100% 10/10"},
+		{cmds.Progress{Done: 512, Total: 3 << 20, Unit: cmds.ProgressBytes}, "[>                             ]   0% 512 B/3.0 MiB"},
+		{cmds.Progress{Done: 1536, Unit: cmds.ProgressBytes}, "1.5 KiB"},
+		{cmds.Progress{Done: 7, Unit: cmds.ProgressItems}, "7 items"},
+	}
+
+	for _, tc := range tcs {
+		if s := formatProgress(tc.p); s != tc.exp {
+			t.Errorf("expected %q, got %q", tc.exp, s)
+		}
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	cmdsre, exitCh := NewResponseEmitter(&stdout, &stderr, nil, &cmds.Request{})
+	re := cmdsre.(*responseEmitter)
+
+	go func() {
+		// not a terminal
+		cmds.SetProgress(re, cmds.Progress{Done: 1, Total: 2})
+		if stderr.Len() != 0 {
+			t.Errorf("expected no progress bar, got %q", stderr.String())
+		}
+
+		re.tty = true
+		re.SetLength(2)
+		cmds.SetProgress(re, cmds.Progress{Done: 10})
+		cmds.SetProgress(re, cmds.Progress{Done: 1})
+		re.Emit("a")
+		cmds.SetProgress(re, cmds.Progress{Done: 2})
+		re.Close()
+	}()
+	<-exitCh
+
+	bar := func(s string) string { return "\r" + s }
+	exp := bar("[==============================] 100% 10/2") +
+		bar("[===============>              ]  50% 1/2 ") +
+		"\r" + "                                         " + "\r" +
+		bar("[==============================] 100% 2/2") + "\n"
+	if stderr.String() != exp {
+		t.Errorf("expected stderr\n%q\ngot\n%q", exp, stderr.String())
+	}
+	if stdout.String() != "a\n" {
+		t.Errorf("expected stdout %q, got %q", "a\n", stdout.String())
+	}
+}
@@ -29,7 +29,12 @@ func NewResponseEmitter(stdout, stderr io.Writer, enc func(*cmds.Request) func(i
 		}
 	}
 
-	return &responseEmitter{stdout: stdout, stderr: stderr, encType: encType, enc: enc(req)(stdout), ch: ch}, ch
+	re := &responseEmitter{stdout: stdout, stderr: stderr, encType: encType, enc: enc(req)(stdout), ch: ch}
+	if f, ok := stderr.(*os.File); ok {
+		re.tty, _ = isTty(f)
+	}
+
+	return re, ch
 }
 
 // ResponseEmitter extends cmds.ResponseEmitter to give better control over the command line
@@ -55,6 +60,11 @@ type responseEmitter struct {
 
 	errOccurred bool
 
+	// tty is set if stderr is a terminal, barLen is the length of the
+	// progress bar drawn on it
+	tty    bool
+	barLen int
+
 	ch chan<- int
 }
 
@@ -79,6 +89,21 @@ func (re *responseEmitter) SetError(v interface{}, errType cmdkit.ErrorType) {
 	}
 }
 
+// Warn prints a warning on stderr. Unlike SetError, it doesn't change the
+// exit code.
+func (re *responseEmitter) Warn(msg string) error {
+	re.wLock.Lock()
+	defer re.wLock.Unlock()
+
+	if re.closed {
+		return io.ErrClosedPipe
+	}
+
+	re.clearProgress()
+	_, err := fmt.Fprintln(re.stderr, "Warning:", msg)
+	return err
+}
+
 func (re *responseEmitter) isClosed() bool {
 	re.wLock.Lock()
 	defer re.wLock.Unlock()
@@ -94,6 +119,11 @@ func (re *responseEmitter) Close() error {
 		return errors.New("closing closed responseemitter")
 	}
 
+	if re.barLen > 0 {
+		fmt.Fprintln(re.stderr)
+		re.barLen = 0
+	}
+
 	re.ch <- re.exit
 	close(re.ch)
 
@@ -164,6 +194,10 @@ func (re *responseEmitter) Emit(v interface{}) error {
 		return nil
 	}
 
+	if w, ok := v.(cmds.Warning); ok {
+		return re.Warn(w.Message)
+	}
+
 	if re.isClosed() {
 		return io.ErrClosedPipe
 	}
@@ -172,6 +206,10 @@ func (re *responseEmitter) Emit(v interface{}) error {
 		v = &err
 	}
 
+	re.wLock.Lock()
+	re.clearProgress()
+	re.wLock.Unlock()
+
 	var err error
 
 	switch t := v.(type) {
@@ -96,3 +96,40 @@ func TestSetError(t *testing.T) {
 		tc.Run(t)
 	}
 }
+
+func TestWarn(t *testing.T) {
+	tcs := []tcSetError{
+		tcSetError{
+			stdout:   bytes.NewBuffer(nil),
+			stderr:   bytes.NewBuffer(nil),
+			exStdout: "a\nb\n",
+			exStderr: "Warning: some warning\n",
+			exExit:   0,
+			f: func(re ResponseEmitter, t *testing.T) {
+				defer re.Close()
+				re.Emit("a")
+				cmds.Warn(re, "some warning")
+				re.Emit("b")
+			},
+		},
+
+		tcSetError{
+			stdout:   bytes.NewBuffer(nil),
+			stderr:   bytes.NewBuffer(nil),
+			exStdout: "a\n",
+			exStderr: "Warning: some warning\nError: some error\n",
+			exExit:   1,
+			f: func(re ResponseEmitter, t *testing.T) {
+				defer re.Close()
+				re.Emit("a")
+				re.Emit(cmds.Warning{Message: "some warning"})
+				re.SetError("some error", cmdkit.ErrNormal)
+			},
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Log(i)
+		tc.Run(t)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"io"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -32,19 +31,21 @@ func Run(ctx context.Context, root *cmds.Command,
 		fmt.Fprintf(stderr, "Error: %s\n", err)
 	}
 
-	req, errParse := Parse(ctx, cmdline[1:], stdin, root)
-
-	// Handle the timeout up front.
-	var cancel func()
-	if timeoutStr, ok := req.Options[cmds.TimeoutOpt]; ok {
-		timeout, err := time.ParseDuration(timeoutStr.(string))
+	// the completion scripts call us to complete the values of commands
+	// with a CompleteFunc
+	if len(cmdline) > 1 && cmdline[1] == CompleteCmd {
+		err := complete(ctx, root, cmdline[2:], stdout, buildEnv)
 		if err != nil {
-			return err
+			printErr(err)
 		}
-		req.Context, cancel = context.WithTimeout(req.Context, timeout)
-	} else {
-		req.Context, cancel = context.WithCancel(req.Context)
+		return err
 	}
+
+	req, errParse := Parse(ctx, cmdline[1:], stdin, root)
+
+	// the timeout is applied by the executor
+	var cancel func()
+	req.Context, cancel = context.WithCancel(req.Context)
 	defer cancel()
 
 	// this is a message to tell the user how to get the help text
@@ -141,6 +142,11 @@ func Run(ctx context.Context, root *cmds.Command,
 		return fmt.Errorf("could not find matching encoder for enctype %#v", encType)
 	}
 
+	// warn about deprecated commands and options
+	for _, w := range req.Deprecations() {
+		fmt.Fprintln(stderr, "Warning:", w)
+	}
+
 	errCh := make(chan error, 1)
 	go func() {
 		err := exctr.Execute(req, re, env)
@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 
@@ -27,6 +28,11 @@ var log = logging.Logger("cmds")
 // It reads from the Request, and writes results to the ResponseEmitter.
 type Function func(*Request, ResponseEmitter, Environment)
 
+// CompleteFunc returns candidates for completing prefix on the command line.
+// The request is the partial request parsed from the words before it, so its
+// options have not been validated and required arguments may be missing.
+type CompleteFunc func(req *Request, env Environment, prefix string) ([]string, error)
+
 // PostRunMap is the map used in Command.PostRun.
 type PostRunMap map[PostRunType]func(*Request, ResponseEmitter) ResponseEmitter
 
@@ -37,6 +43,31 @@ type Command struct {
 	Arguments []cmdkit.Argument
 	PreRun    func(req *Request, env Environment) error
 
+	// OptionGroups declare which options of this command and its parents
+	// must or must not be used together. They are checked when the request's
+	// options are converted and before defaults are filled in.
+	OptionGroups []OptionGroup
+
+	// OptionValues maps option names to the values shell completions suggest
+	// for them, e.g. the supported formats of a --format option.
+	OptionValues map[string][]string
+
+	// Complete returns shell completions for the arguments of this command
+	// that have no entry in ArgumentCompletions.
+	Complete CompleteFunc
+
+	// ArgumentCompletions and OptionCompletions map names of arguments and
+	// options to functions returning shell completions for their values.
+	// Option completions also apply to subcommands, unless they declare their
+	// own.
+	ArgumentCompletions map[string]CompleteFunc
+	OptionCompletions   map[string]CompleteFunc
+
+	// ArgumentValidators maps names of string arguments to validators that
+	// check their values. They are run by CheckArguments, so invalid values
+	// are rejected with a client error before Run is called.
+	ArgumentValidators map[string][]Validator
+
 	// Run is the function that processes the request to generate a response.
 	// Note that when executing the command over the HTTP API you can only read
 	// after writing when using multipart requests. The request body will not be
@@ -57,6 +88,37 @@ type Command struct {
 	// ie. If command Run returns &Block{}, then Command.Type == &Block{}
 	Type        interface{}
 	Subcommands map[string]*Command
+
+	// Deprecated is set if the command is about to be removed. Callers get a
+	// warning when they invoke it and it is hidden from the short help text.
+	Deprecated *Deprecation
+
+	// Hidden commands can be called but are not listed in help texts,
+	// generated documentation or shell completions.
+	Hidden bool
+
+	// Aliases are alternative names under which this command can be called
+	// in addition to the name it is registered with in its parent's
+	// Subcommands, e.g. to keep old names working after renaming a command.
+	Aliases []string
+
+	// Timeout is how long the command may run if the caller does not set the
+	// timeout option, MaxTimeout is the longest it may run regardless. Zero
+	// means no limit. The executors apply them to the request context.
+	Timeout    time.Duration
+	MaxTimeout time.Duration
+
+	// OptionSources provide values for options the caller did not set, e.g.
+	// from environment variables or a config file. They are consulted in
+	// order before falling back to the declared defaults. Only the sources of
+	// the root command are used, and only on the command line, not by the
+	// HTTP handler.
+	OptionSources []OptionSource
+
+	// Middleware wraps the execution of this command and all of its
+	// subcommands. The middleware of a command runs outside of the middleware
+	// of its subcommands. For commands sent to a server it only runs there.
+	Middleware []Middleware
 }
 
 // ErrNotCallable signals a command that cannot be called.
@@ -78,53 +140,94 @@ func (c *Command) Call(req *Request, re ResponseEmitter, env Environment) {
 		return
 	}
 
-	if cmd.Run == nil {
-		re.SetError(ErrNotCallable, cmdkit.ErrFatal)
+	mws, err := c.GetMiddleware(req.Path)
+	if err != nil {
+		re.SetError(err, cmdkit.ErrFatal)
 		return
 	}
 
-	err = cmd.CheckArguments(req)
+	req, cancel, err := req.WithTimeout()
 	if err != nil {
-		re.SetError(err, cmdkit.ErrFatal)
+		re.SetError(err, cmdkit.ErrClient)
 		return
 	}
+	defer cancel()
 
-	// If this ResponseEmitter encodes messages (e.g. http, cli or writer - but not chan),
-	// we need to update the encoding to the one specified by the command.
-	if re_, ok := re.(EncodingEmitter); ok {
-		encType := GetEncoding(req)
-
-		if enc, ok := cmd.Encoders[encType]; ok {
-			re_.SetEncoder(enc(req))
-		} else if enc, ok := Encoders[encType]; ok {
-			re_.SetEncoder(enc(req))
-		} else {
-			log.Errorf("unknown encoding %q, using json", encType)
-			re_.SetEncoder(Encoders[JSON](req))
+	call := func(req *Request, re ResponseEmitter, env Environment) error {
+		if cmd.Run == nil {
+			return ErrNotCallable
 		}
+
+		err := cmd.CheckArguments(req)
+		if err != nil {
+			return err
+		}
+
+		SetEncoder(req, re, GetEncoding(req))
+
+		req, span := req.WithSpan("cmds.run")
+		defer span.Finish()
+
+		cmd.Run(req, re, env)
+		return nil
 	}
 
-	cmd.Run(req, re, env)
+	err = Chain(ExecutorFunc(call), mws...).Execute(req, re, env)
+	if err != nil {
+		// keep the code of errors like those of the validators
+		code := cmdkit.ErrFatal
+		if e, ok := err.(*cmdkit.Error); ok {
+			code = e.Code
+		}
+		re.SetError(err, code)
+	}
 }
 
 // Resolve returns the subcommands at the given path
 func (c *Command) Resolve(pth []string) ([]*Command, error) {
+	cmds, _, err := c.resolve(pth)
+	return cmds, err
+}
+
+// resolve returns the subcommands at the given path together with the path
+// made up of their canonical names, i.e. with all aliases replaced.
+func (c *Command) resolve(pth []string) ([]*Command, []string, error) {
 	cmds := make([]*Command, len(pth)+1)
 	cmds[0] = c
+	canonical := make([]string, len(pth))
 
 	cmd := c
 	for i, name := range pth {
-		cmd = cmd.Subcommands[name]
+		canonical[i], cmd = cmd.Subcommand(name)
 
 		if cmd == nil {
 			pathS := strings.Join(pth[:i], "/")
-			return nil, fmt.Errorf("undefined command: %q", pathS)
+			return nil, nil, fmt.Errorf("undefined command: %q", pathS)
 		}
 
 		cmds[i+1] = cmd
 	}
 
-	return cmds, nil
+	return cmds, canonical, nil
+}
+
+// Subcommand returns the subcommand with the given name or alias and the name
+// it is registered with in Subcommands. If there is no such subcommand it
+// returns nil.
+func (c *Command) Subcommand(name string) (string, *Command) {
+	if sub, ok := c.Subcommands[name]; ok {
+		return name, sub
+	}
+
+	for subName, sub := range c.Subcommands {
+		for _, alias := range sub.Aliases {
+			if alias == name {
+				return subName, sub
+			}
+		}
+	}
+
+	return name, nil
 }
 
 // Get resolves and returns the Command addressed by path
@@ -163,7 +266,24 @@ func (c *Command) GetOptions(path []string) (map[string]cmdkit.Option, error) {
 	return optionsMap, nil
 }
 
-// DebugValidate checks if the command tree is well-formed.
+// GetMiddleware returns the middleware in the given path of commands,
+// starting with the root's.
+func (c *Command) GetMiddleware(path []string) ([]Middleware, error) {
+	cmds, err := c.Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mws []Middleware
+	for _, cmd := range cmds {
+		mws = append(mws, cmd.Middleware...)
+	}
+
+	return mws, nil
+}
+
+// DebugValidate checks if the command tree is well-formed. Lint does further
+// checks.
 //
 // This operation is slow and should be called from tests only.
 func (c *Command) DebugValidate() map[string][]error {
@@ -172,6 +292,14 @@ func (c *Command) DebugValidate() map[string][]error {
 
 	liveOptions := make(map[string]struct{})
 	visit = func(path string, cm *Command) {
+		if cm.Deprecated != nil && cm.Deprecated.Replacement == "" {
+			errs[path] = append(errs[path], fmt.Errorf("deprecated command has no replacement"))
+		}
+
+		if cm.MaxTimeout > 0 && cm.Timeout > cm.MaxTimeout {
+			errs[path] = append(errs[path], fmt.Errorf("timeout %s exceeds maximum timeout %s", cm.Timeout, cm.MaxTimeout))
+		}
+
 		expectOptional := false
 		for i, argDef := range cm.Arguments {
 			// No required arguments after optional arguments.
@@ -190,6 +318,18 @@ func (c *Command) DebugValidate() map[string][]error {
 			}
 		}
 
+		for name := range cm.ArgumentValidators {
+			found := false
+			for _, argDef := range cm.Arguments {
+				if argDef.Name == name && argDef.Type == cmdkit.ArgString {
+					found = true
+				}
+			}
+			if !found {
+				errs[path] = append(errs[path], fmt.Errorf("validators for unknown string argument %s", name))
+			}
+		}
+
 		var goodOptions []string
 		for _, option := range cm.Options {
 			for _, name := range option.Names() {
@@ -201,8 +341,58 @@ func (c *Command) DebugValidate() map[string][]error {
 				}
 			}
 		}
+
+		for _, g := range cm.OptionGroups {
+			for _, name := range g.Names {
+				if _, ok := liveOptions[name]; !ok {
+					errs[path] = append(errs[path], fmt.Errorf("option group refers to unknown option %s", name))
+				}
+			}
+		}
+
+		for name := range cm.OptionValues {
+			if _, ok := liveOptions[name]; !ok {
+				errs[path] = append(errs[path], fmt.Errorf("values for unknown option %s", name))
+			}
+		}
+
+		for name := range cm.OptionCompletions {
+			if _, ok := liveOptions[name]; !ok {
+				errs[path] = append(errs[path], fmt.Errorf("completion for unknown option %s", name))
+			}
+		}
+
+		for name := range cm.ArgumentCompletions {
+			found := false
+			for _, argDef := range cm.Arguments {
+				found = found || argDef.Name == name
+			}
+			if !found {
+				errs[path] = append(errs[path], fmt.Errorf("completion for unknown argument %s", name))
+			}
+		}
+
+		aliases := make(map[string]string)
+		for scName, sc := range cm.Subcommands {
+			if sc == nil {
+				// reported by Lint
+				continue
+			}
+			for _, alias := range sc.Aliases {
+				if _, ok := cm.Subcommands[alias]; ok {
+					errs[path] = append(errs[path], fmt.Errorf("alias %s of subcommand %s collides with subcommand name", alias, scName))
+				} else if other, ok := aliases[alias]; ok {
+					errs[path] = append(errs[path], fmt.Errorf("alias %s used by subcommands %s and %s", alias, other, scName))
+				} else {
+					aliases[alias] = scName
+				}
+			}
+		}
+
 		for scName, sc := range cm.Subcommands {
-			visit(fmt.Sprintf("%s/%s", path, scName), sc)
+			if sc != nil {
+				visit(fmt.Sprintf("%s/%s", path, scName), sc)
+			}
 		}
 
 		for _, name := range goodOptions {
@@ -217,7 +407,7 @@ func (c *Command) DebugValidate() map[string][]error {
 }
 
 // CheckArguments checks that we have all the required string arguments, loading
-// any from stdin if necessary.
+// any from stdin if necessary, and runs the ArgumentValidators on them.
 func (c *Command) CheckArguments(req *Request) error {
 	if len(c.Arguments) == 0 {
 		return nil
@@ -279,7 +469,7 @@ func (c *Command) CheckArguments(req *Request) error {
 		return fmt.Errorf("argument %q is required", argDef.Name)
 	}
 
-	return nil
+	return c.validateArguments(req)
 }
 
 type CommandVisitor func(*Command)
@@ -185,6 +185,66 @@ func TestResolving(t *testing.T) {
 	}
 }
 
+func TestResolvingAliases(t *testing.T) {
+	cmdB := &Command{Aliases: []string{"bee", "b2"}}
+	cmdA := &Command{
+		Aliases: []string{"aa"},
+		Subcommands: map[string]*Command{
+			"b": cmdB,
+		},
+	}
+	cmd := &Command{
+		Subcommands: map[string]*Command{
+			"a": cmdA,
+		},
+	}
+
+	cmds, err := cmd.Resolve([]string{"aa", "b2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 3 || cmds[1] != cmdA || cmds[2] != cmdB {
+		t.Error("Returned command path is different than expected", cmds)
+	}
+
+	req, err := NewRequest(context.Background(), []string{"aa", "bee"}, nil, nil, nil, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Command != cmdB {
+		t.Error("request has wrong command")
+	}
+	if len(req.Path) != 2 || req.Path[0] != "a" || req.Path[1] != "b" {
+		t.Errorf("expected canonical path [a b], got %v", req.Path)
+	}
+}
+
+func TestDebugValidateAliases(t *testing.T) {
+	cmd := &Command{
+		Subcommands: map[string]*Command{
+			"a": &Command{Aliases: []string{"b"}},
+			"b": &Command{},
+		},
+	}
+
+	errs := cmd.DebugValidate()
+	if len(errs[""]) != 1 {
+		t.Errorf("expected one alias collision, got %v", errs)
+	}
+
+	cmd = &Command{
+		Subcommands: map[string]*Command{
+			"a": &Command{Aliases: []string{"c"}},
+			"b": &Command{Aliases: []string{"c"}},
+		},
+	}
+
+	errs = cmd.DebugValidate()
+	if len(errs[""]) != 1 {
+		t.Errorf("expected one duplicate alias, got %v", errs)
+	}
+}
+
 func TestWalking(t *testing.T) {
 	cmdA := &Command{
 		Subcommands: map[string]*Command{
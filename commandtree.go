@@ -0,0 +1,304 @@
+package cmds
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// CommandInfo is a machine-readable description of a command.
+type CommandInfo struct {
+	Path        []string
+	Aliases     []string     `json:",omitempty"`
+	Deprecated  *Deprecation `json:",omitempty"`
+	Helptext    cmdkit.HelpText
+	Options     []OptionInfo
+	Arguments   []ArgumentInfo
+	Encodings   []EncodingType
+	Callable    bool
+	Hidden      bool
+	Subcommands []string
+
+	// Type is the JSON schema of the values emitted by the command.
+	Type map[string]interface{} `json:",omitempty"`
+}
+
+// OptionInfo describes an option of a command.
+type OptionInfo struct {
+	Names       []string
+	Type        string
+	Default     interface{}  `json:",omitempty"`
+	Description string       `json:",omitempty"`
+	Deprecated  *Deprecation `json:",omitempty"`
+}
+
+// ArgumentInfo describes an argument of a command.
+type ArgumentInfo struct {
+	Name          string
+	Type          string
+	Required      bool
+	Variadic      bool
+	SupportsStdin bool
+	Recursive     bool
+	Description   string `json:",omitempty"`
+}
+
+// ExportCommands describes root and all of its subcommands, ordered by path.
+// Only the options declared by each command are listed, not those inherited
+// from its parents.
+func ExportCommands(root *Command) []*CommandInfo {
+	// carry the path down instead of looking it up by command, the same
+	// command may be the subcommand of several parents
+	var infos []*CommandInfo
+	var export func(path []string, cmd *Command)
+	export = func(path []string, cmd *Command) {
+		infos = append(infos, commandInfo(path, cmd))
+		for name, sub := range cmd.Subcommands {
+			export(append(path[:len(path):len(path)], name), sub)
+		}
+	}
+	export([]string{}, root)
+
+	sort.Slice(infos, func(i, j int) bool {
+		return strings.Join(infos[i].Path, " ") < strings.Join(infos[j].Path, " ")
+	})
+	return infos
+}
+
+func commandInfo(path []string, cmd *Command) *CommandInfo {
+	info := &CommandInfo{
+		Path:        path,
+		Aliases:     cmd.Aliases,
+		Deprecated:  cmd.Deprecated,
+		Helptext:    cmd.Helptext,
+		Options:     []OptionInfo{},
+		Arguments:   []ArgumentInfo{},
+		Encodings:   commandEncodings(cmd),
+		Callable:    cmd.Run != nil,
+		Hidden:      cmd.Hidden,
+		Subcommands: []string{},
+		Type:        JSONSchema(cmd.Type),
+	}
+
+	for _, opt := range cmd.Options {
+		info.Options = append(info.Options, OptionInfo{
+			Names:       opt.Names(),
+			Type:        opt.Type().String(),
+			Default:     opt.Default(),
+			Description: opt.Description(),
+			Deprecated:  OptionDeprecation(opt),
+		})
+	}
+
+	for _, arg := range cmd.Arguments {
+		typ := "string"
+		if arg.Type == cmdkit.ArgFile {
+			typ = "file"
+		}
+
+		info.Arguments = append(info.Arguments, ArgumentInfo{
+			Name:          arg.Name,
+			Type:          typ,
+			Required:      arg.Required,
+			Variadic:      arg.Variadic,
+			SupportsStdin: arg.SupportsStdin,
+			Recursive:     arg.Recursive,
+			Description:   arg.Description,
+		})
+	}
+
+	for name := range cmd.Subcommands {
+		info.Subcommands = append(info.Subcommands, name)
+	}
+	sort.Strings(info.Subcommands)
+
+	return info
+}
+
+// commandEncodings returns the encodings the output of cmd can be requested
+// in. Text is only listed if the command has its own text encoder because
+// otherwise the executor falls back to JSON.
+func commandEncodings(cmd *Command) []EncodingType {
+	encs := make(map[EncodingType]struct{})
+	for enc := range Encoders {
+		if enc != Text {
+			encs[enc] = struct{}{}
+		}
+	}
+	for enc := range cmd.Encoders {
+		encs[enc] = struct{}{}
+	}
+
+	out := make([]EncodingType, 0, len(encs))
+	for enc := range encs {
+		out = append(out, enc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// JSONSchema returns the JSON schema of the JSON encoding of values of the
+// same type as v. Types with custom JSON marshalling are described by the
+// empty schema. It returns nil if v is nil.
+func JSONSchema(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	return typeSchema(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+func typeSchema(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return map[string]interface{}{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), visiting)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), visiting),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), visiting),
+		}
+	case reflect.Struct:
+		// recursive types are not expanded a second time
+		if visiting[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		props := make(map[string]interface{})
+		required := []string{}
+		structSchema(t, visiting, props, &required)
+
+		schema := map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+		}
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		return schema
+	default:
+		// interfaces, channels, functions: anything goes
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema adds the properties of the fields of t to props, following the
+// rules of encoding/json for field names and embedded structs.
+func structSchema(t reflect.Type, visiting map[reflect.Type]bool, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := f.Name
+		omitempty := false
+		if tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitempty = true
+				}
+			}
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && ft.Kind() == reflect.Struct && (tag == "" || strings.HasPrefix(tag, ",")) {
+			structSchema(ft, visiting, props, required)
+			continue
+		}
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		props[name] = typeSchema(f.Type, visiting)
+		if !omitempty {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// CommandsCommand returns a command that emits the descriptions of root and
+// all of its subcommands as returned by ExportCommands. It is not part of
+// any command tree by default, add it e.g. as root.Subcommands["commands"].
+func CommandsCommand(root *Command) *Command {
+	return &Command{
+		Helptext: cmdkit.HelpText{
+			Tagline: "List all available commands.",
+			ShortDescription: `
+Lists all available commands with their options, arguments and output
+types. Use the JSON encoding for a machine-readable description.
+`,
+		},
+		Run: func(req *Request, re ResponseEmitter, env Environment) {
+			re.Emit(ExportCommands(root))
+		},
+		Encoders: EncoderMap{
+			Text: MakeEncoder(func(req *Request, w io.Writer, v interface{}) error {
+				infos, ok := v.([]*CommandInfo)
+				if !ok {
+					return fmt.Errorf("unexpected type: %T", v)
+				}
+
+				for _, info := range infos {
+					if len(info.Path) == 0 {
+						continue
+					}
+					_, err := fmt.Fprintln(w, strings.Join(info.Path, " "))
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}),
+		},
+		Type: []*CommandInfo{},
+	}
+}
@@ -0,0 +1,161 @@
+package cmds
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+type treeTestOutput struct {
+	Name     string
+	Size     int64 `json:"size,omitempty"`
+	Tags     []string
+	Children []*treeTestOutput
+	Data     []byte
+	ignored  bool
+	Skip     string `json:"-"`
+}
+
+func TestExportCommands(t *testing.T) {
+	root := &Command{
+		Options: []cmdkit.Option{
+			cmdkit.BoolOption("verbose", "v", "Be verbose."),
+		},
+		Subcommands: map[string]*Command{
+			"ls": &Command{
+				Aliases: []string{"list"},
+				Arguments: []cmdkit.Argument{
+					cmdkit.StringArg("path", true, true, "The paths."),
+					cmdkit.FileArg("file", false, false, "A file.").EnableStdin(),
+				},
+				Options: []cmdkit.Option{
+					cmdkit.IntOption("depth", "Depth.").WithDefault(1),
+				},
+				Encoders: EncoderMap{
+					Text: Encoders[TextNewline],
+				},
+				Run:  noop,
+				Type: &treeTestOutput{},
+			},
+		},
+	}
+	root.Subcommands["commands"] = CommandsCommand(root)
+
+	infos := ExportCommands(root)
+	if len(infos) != 3 {
+		t.Fatalf("expected 3 commands, got %d", len(infos))
+	}
+
+	paths := [][]string{{}, {"commands"}, {"ls"}}
+	for i, p := range paths {
+		if !reflect.DeepEqual(infos[i].Path, p) {
+			t.Errorf("expected path %v at %d, got %v", p, i, infos[i].Path)
+		}
+	}
+
+	if infos[0].Callable || !reflect.DeepEqual(infos[0].Subcommands, []string{"commands", "ls"}) {
+		t.Errorf("unexpected root info: %+v", infos[0])
+	}
+
+	ls := infos[2]
+	if !ls.Callable || !reflect.DeepEqual(ls.Aliases, []string{"list"}) {
+		t.Errorf("unexpected ls info: %+v", ls)
+	}
+	expOpts := []OptionInfo{{Names: []string{"depth"}, Type: "int", Default: 1, Description: "Depth. Default: 1."}}
+	if !reflect.DeepEqual(ls.Options, expOpts) {
+		t.Errorf("expected options %+v, got %+v", expOpts, ls.Options)
+	}
+	expArgs := []ArgumentInfo{
+		{Name: "path", Type: "string", Required: true, Variadic: true, Description: "The paths."},
+		{Name: "file", Type: "file", SupportsStdin: true, Description: "A file."},
+	}
+	if !reflect.DeepEqual(ls.Arguments, expArgs) {
+		t.Errorf("expected arguments %+v, got %+v", expArgs, ls.Arguments)
+	}
+
+	hasText := false
+	for _, enc := range ls.Encodings {
+		hasText = hasText || enc == Text
+	}
+	if !hasText {
+		t.Errorf("expected ls to support text encoding, got %v", ls.Encodings)
+	}
+	for _, enc := range infos[0].Encodings {
+		if enc == Text {
+			t.Errorf("root should not support text encoding, got %v", infos[0].Encodings)
+		}
+	}
+
+	props := ls.Type["properties"].(map[string]interface{})
+	for _, name := range []string{"Name", "size", "Tags", "Children", "Data"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("schema is missing property %q: %v", name, props)
+		}
+	}
+	if len(props) != 5 {
+		t.Errorf("expected 5 properties, got %v", props)
+	}
+	if props["size"].(map[string]interface{})["type"] != "integer" {
+		t.Errorf("expected size to be an integer, got %v", props["size"])
+	}
+	if props["Data"].(map[string]interface{})["type"] != "string" {
+		t.Errorf("expected Data to be a string, got %v", props["Data"])
+	}
+	children := props["Children"].(map[string]interface{})["items"].(map[string]interface{})
+	if children["type"] != "object" {
+		t.Errorf("expected recursive type to be an object, got %v", children)
+	}
+	if !reflect.DeepEqual(ls.Type["required"], []string{"Children", "Data", "Name", "Tags"}) {
+		t.Errorf("unexpected required properties %v", ls.Type["required"])
+	}
+}
+
+func TestExportCommandsShared(t *testing.T) {
+	ls := &Command{Run: noop}
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"a": &Command{Subcommands: map[string]*Command{"ls": ls}},
+			"b": &Command{Subcommands: map[string]*Command{"ls": ls}},
+		},
+	}
+
+	var paths [][]string
+	for _, info := range ExportCommands(root) {
+		paths = append(paths, info.Path)
+	}
+
+	exp := [][]string{{}, {"a"}, {"a", "ls"}, {"b"}, {"b", "ls"}}
+	if !reflect.DeepEqual(paths, exp) {
+		t.Errorf("expected paths %v, got %v", exp, paths)
+	}
+}
+
+func TestCommandsCommand(t *testing.T) {
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"a": &Command{Run: noop},
+			"b": &Command{
+				Subcommands: map[string]*Command{
+					"c": &Command{Run: noop},
+				},
+			},
+		},
+	}
+	root.Subcommands["commands"] = CommandsCommand(root)
+
+	req, err := NewRequest(nil, []string{"commands"}, cmdkit.OptMap{EncLong: Text}, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	re := NewWriterResponseEmitter(writecloser{Writer: &buf, Closer: nopCloser{}}, req, Encoders[JSON])
+
+	root.Call(req, re, nil)
+
+	if buf.String() != "a\nb\nb c\ncommands\n" {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,114 @@
+package cmds
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// Deprecation describes why and until when a command or option is deprecated.
+type Deprecation struct {
+	// Message explains why the command or option is deprecated.
+	Message string
+
+	// Replacement is what should be used instead, e.g. the new name of the
+	// command or option.
+	Replacement string
+
+	// RemovalVersion is the version in which the command or option will be
+	// removed.
+	RemovalVersion string
+}
+
+func (d *Deprecation) String() string {
+	var parts []string
+	if d.Message != "" {
+		parts = append(parts, d.Message)
+	}
+	if d.Replacement != "" {
+		parts = append(parts, fmt.Sprintf("use %s instead", d.Replacement))
+	}
+	if d.RemovalVersion != "" {
+		parts = append(parts, fmt.Sprintf("will be removed in %s", d.RemovalVersion))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+type deprecatedOption struct {
+	cmdkit.Option
+	deprecation *Deprecation
+}
+
+func (o *deprecatedOption) WithDefault(v interface{}) cmdkit.Option {
+	return &deprecatedOption{Option: o.Option.WithDefault(v), deprecation: o.deprecation}
+}
+
+// DeprecateOption marks opt as deprecated. Callers get a warning when they set
+// the option and it is hidden from the short help text.
+func DeprecateOption(opt cmdkit.Option, d Deprecation) cmdkit.Option {
+	return &deprecatedOption{Option: opt, deprecation: &d}
+}
+
+// OptionDeprecation returns the deprecation info of opt, or nil if opt is not
+// deprecated.
+func OptionDeprecation(opt cmdkit.Option) *Deprecation {
+	if do, ok := opt.(*deprecatedOption); ok {
+		return do.deprecation
+	}
+	return nil
+}
+
+// Deprecations returns warnings for the deprecated commands in the request
+// path and for the deprecated options set in the request. Options that are
+// set to their default value are not reported.
+func (req *Request) Deprecations() []string {
+	var warnings []string
+
+	warn := func(what string, d *Deprecation) {
+		w := what + " is deprecated"
+		if s := d.String(); s != "" {
+			w += ": " + s
+		}
+		warnings = append(warnings, w)
+	}
+
+	var optDefs map[string]cmdkit.Option
+	if req.Root != nil {
+		cmds, _ := req.Root.Resolve(req.Path)
+		for i := 1; i < len(cmds); i++ {
+			if d := cmds[i].Deprecated; d != nil {
+				warn(fmt.Sprintf("command %q", strings.Join(req.Path[:i], " ")), d)
+			}
+		}
+
+		optDefs, _ = req.Root.GetOptions(req.Path)
+	} else if req.Command != nil && req.Command.Deprecated != nil {
+		warn(fmt.Sprintf("command %q", strings.Join(req.Path, " ")), req.Command.Deprecated)
+	}
+
+	names := make([]string, 0, len(req.Options))
+	for k := range req.Options {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		opt, ok := optDefs[k]
+		if !ok {
+			continue
+		}
+
+		d := OptionDeprecation(opt)
+		if v := req.Options[k]; d == nil || (opt.Default() != nil && reflect.DeepEqual(opt.Default(), v)) {
+			continue
+		}
+
+		warn(fmt.Sprintf("option %q", k), d)
+	}
+
+	return warnings
+}
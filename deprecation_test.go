@@ -0,0 +1,81 @@
+package cmds
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+func TestDeprecations(t *testing.T) {
+	root := &Command{
+		Options: []cmdkit.Option{
+			DeprecateOption(cmdkit.BoolOption("old-flag", "An old flag."), Deprecation{Replacement: "--new-flag"}),
+			DeprecateOption(cmdkit.IntOption("level", "An old level."), Deprecation{}).WithDefault(1),
+		},
+		Subcommands: map[string]*Command{
+			"old": &Command{
+				Deprecated: &Deprecation{
+					Message:        "it is slow",
+					Replacement:    "new",
+					RemovalVersion: "v0.5.0",
+				},
+				Run: noop,
+			},
+			"new": &Command{
+				Run: noop,
+			},
+		},
+	}
+
+	if OptionDeprecation(root.Options[1]) == nil {
+		t.Fatal("WithDefault dropped the deprecation")
+	}
+
+	req, err := NewRequest(context.Background(), []string{"new"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.FillDefaults()
+	if ws := req.Deprecations(); len(ws) != 0 {
+		t.Errorf("expected no warnings, got %v", ws)
+	}
+
+	req, err = NewRequest(context.Background(), []string{"old"}, map[string]interface{}{
+		"old-flag": true,
+		"level":    2,
+	}, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`command "old" is deprecated: it is slow; use new instead; will be removed in v0.5.0`,
+		`option "level" is deprecated`,
+		`option "old-flag" is deprecated: use --new-flag instead`,
+	}
+	ws := req.Deprecations()
+	if len(ws) != len(expected) {
+		t.Fatalf("expected warnings %v, got %v", expected, ws)
+	}
+	for i := range ws {
+		if ws[i] != expected[i] {
+			t.Errorf("expected warning %q, got %q", expected[i], ws[i])
+		}
+	}
+}
+
+func TestDebugValidateDeprecated(t *testing.T) {
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"a": &Command{Deprecated: &Deprecation{Replacement: "b"}},
+			"b": &Command{},
+			"c": &Command{Deprecated: &Deprecation{Message: "gone"}},
+		},
+	}
+
+	errs := root.DebugValidate()
+	if len(errs) != 1 || len(errs["/c"]) != 1 {
+		t.Errorf("expected one error for /c, got %v", errs)
+	}
+}
@@ -0,0 +1,140 @@
+package cmds
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// ReqLogCommand returns a command tree to list, cancel and clear the requests
+// in rl. It is not part of any command tree by default, add it e.g. as
+// diag.Subcommands["cmds"]. The environment must log the requests in rl for
+// them to be listed and cancellable.
+func ReqLogCommand(rl *ReqLog) *Command {
+	return &Command{
+		Helptext: cmdkit.HelpText{
+			Tagline: "List commands run on this node.",
+			ShortDescription: `
+Lists running and recently finished commands, optionally only those matching
+the given options. Use the cancel subcommand to stop a running command by its
+ID.
+`,
+		},
+		Options: []cmdkit.Option{
+			cmdkit.BoolOption("verbose", "v", "Print extra information."),
+			cmdkit.StringOption("command", "Only list the given command and its subcommands, e.g. block/rm."),
+			cmdkit.BoolOption("active", "Only list running commands."),
+			cmdkit.BoolOption("failed", "Only list commands that failed."),
+		},
+		Run: func(req *Request, re ResponseEmitter, env Environment) {
+			var q ReqLogQuery
+			q.Command, _ = req.Options["command"].(string)
+			q.Active, _ = req.Options["active"].(bool)
+			q.Failed, _ = req.Options["failed"].(bool)
+
+			entries := rl.Query(q)
+			if entries == nil {
+				entries = []*ReqLogEntry{}
+			}
+			re.Emit(entries)
+		},
+		Encoders: EncoderMap{
+			Text: MakeEncoder(func(req *Request, w io.Writer, v interface{}) error {
+				entries, ok := v.([]*ReqLogEntry)
+				if !ok {
+					return fmt.Errorf("unexpected type: %T", v)
+				}
+
+				verbose, _ := req.Options["verbose"].(bool)
+				return writeReqLog(w, entries, verbose, time.Now())
+			}),
+		},
+		Type: []*ReqLogEntry{},
+		Subcommands: map[string]*Command{
+			"cancel": &Command{
+				Helptext: cmdkit.HelpText{
+					Tagline: "Cancel a running command.",
+				},
+				Arguments: []cmdkit.Argument{
+					cmdkit.StringArg("id", true, true, "The IDs of the commands to cancel."),
+				},
+				ArgumentValidators: map[string][]Validator{
+					"id": {IntRange(0, math.MaxInt32)},
+				},
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					for _, arg := range req.Arguments {
+						id, _ := strconv.Atoi(arg)
+						if err := rl.Cancel(id); err != nil {
+							re.SetError(err, cmdkit.ErrClient)
+							return
+						}
+					}
+				},
+			},
+			"clear": &Command{
+				Helptext: cmdkit.HelpText{
+					Tagline: "Clear inactive commands from the log.",
+				},
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					rl.ClearInactive()
+				},
+			},
+			"set-keep-time": &Command{
+				Helptext: cmdkit.HelpText{
+					Tagline: "Set how long finished commands are kept in the log.",
+				},
+				Arguments: []cmdkit.Argument{
+					cmdkit.StringArg("time", true, false, "The time to keep finished commands, e.g. 10m."),
+				},
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					d, err := time.ParseDuration(req.Arguments[0])
+					if err != nil || d < 0 {
+						re.SetError(fmt.Sprintf("invalid time %q: expected a duration like 10m", req.Arguments[0]), cmdkit.ErrClient)
+						return
+					}
+					rl.SetKeepTime(d)
+				},
+			},
+		},
+	}
+}
+
+func writeReqLog(w io.Writer, entries []*ReqLogEntry, verbose bool, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+
+	fmt.Fprint(tw, "ID\tState\tCommand\t")
+	if verbose {
+		fmt.Fprint(tw, "Args\tOptions\tValues\tBytes\tError\t")
+	}
+	fmt.Fprintln(tw, "Duration")
+
+	for _, e := range entries {
+		state := "done"
+		switch {
+		case e.Canceled:
+			state = "canceled"
+		case e.Active && e.Queued:
+			state = "queued"
+		case e.Active:
+			state = "running"
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t", e.ID, state, e.Command)
+		if verbose {
+			fmt.Fprintf(tw, "%v\t%v\t%d\t%d\t%s\t", e.Args, e.Options, e.Values, e.Bytes, e.Error)
+		}
+
+		end := e.EndTime
+		if e.Active {
+			end = now
+		}
+		fmt.Fprintln(tw, end.Sub(e.StartTime).Round(time.Millisecond))
+	}
+
+	return tw.Flush()
+}
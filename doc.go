@@ -68,6 +68,11 @@
 	should call Error(). Depending on the reponse type, other
 	errors may also occur.
 
+	Instead of calling Next in a loop, the values can be read into
+	a variable of the expected type with NextValue, ForEach or
+	Collect, or ReadOnce for commands that use EmitOnce. They
+	return the emitted error as a *cmdkit.Error.
+
 	Pipes
 
 	Pipes are pairs (emitter, response), such that a value emitted
@@ -1,6 +1,7 @@
 package cmds
 
 import (
+	"bufio"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -33,6 +34,7 @@ const (
 	JSON        = "json"
 	XML         = "xml"
 	Protobuf    = "protobuf"
+	CBOR        = "cbor"
 	Text        = "text"
 	TextNewline = "textnl"
 
@@ -47,6 +49,12 @@ var Decoders = map[EncodingType]func(w io.Reader) Decoder{
 	JSON: func(r io.Reader) Decoder {
 		return json.NewDecoder(r)
 	},
+	Protobuf: func(r io.Reader) Decoder {
+		return &protoDecoder{r: bufio.NewReader(r)}
+	},
+	CBOR: func(r io.Reader) Decoder {
+		return &cborDecoder{r: bufio.NewReader(r)}
+	},
 }
 
 type EncoderFunc func(req *Request) func(w io.Writer) Encoder
@@ -59,6 +67,12 @@ var Encoders = EncoderMap{
 	JSON: func(req *Request) func(io.Writer) Encoder {
 		return func(w io.Writer) Encoder { return json.NewEncoder(w) }
 	},
+	Protobuf: func(req *Request) func(io.Writer) Encoder {
+		return func(w io.Writer) Encoder { return &protoEncoder{w: w} }
+	},
+	CBOR: func(req *Request) func(io.Writer) Encoder {
+		return func(w io.Writer) Encoder { return &cborEncoder{w: w} }
+	},
 	Text: func(req *Request) func(io.Writer) Encoder {
 		return func(w io.Writer) Encoder { return TextEncoder{w: w} }
 	},
@@ -67,6 +81,18 @@ var Encoders = EncoderMap{
 	},
 }
 
+// IsFrameEncoding reports whether the builtin encoder of enc marks progress
+// and warnings, so they can be sent between the values and told apart from
+// them.
+func IsFrameEncoding(enc EncodingType) bool {
+	switch enc {
+	case JSON, Protobuf, CBOR:
+		return true
+	default:
+		return false
+	}
+}
+
 func MakeEncoder(f func(*Request, io.Writer, interface{}) error) func(*Request) func(io.Writer) Encoder {
 	return func(req *Request) func(io.Writer) Encoder {
 		return func(w io.Writer) Encoder { return &genericEncoder{f: f, w: w, req: req} }
@@ -153,6 +153,35 @@ var RootCmd = &cmds.Command{
 				},
 			},
 		},
+		// report progress besides the result, so it doesn't end up in the
+		// output of JSON consumers
+		"progressAdd": &cmds.Command{
+			Arguments: []cmdkit.Argument{
+				cmdkit.StringArg("summands", true, true, "values that are supposed to be summed"),
+			},
+			Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+				sum := 0
+
+				for i, str := range req.Arguments {
+					num, err := strconv.Atoi(str)
+					if err != nil {
+						re.SetError(err, cmdkit.ErrNormal)
+						return
+					}
+
+					sum += num
+					cmds.SetProgress(re, cmds.Progress{
+						Done:  uint64(i + 1),
+						Total: uint64(len(req.Arguments)),
+						Unit:  cmds.ProgressItems,
+					})
+					time.Sleep(200 * time.Millisecond)
+				}
+
+				cmds.EmitOnce(re, sum)
+			},
+			Type: 0,
+		},
 		// how to set program's return value
 		"exitAdd": &cmds.Command{
 			Arguments: []cmdkit.Argument{
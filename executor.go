@@ -2,6 +2,7 @@ package cmds
 
 import (
 	"context"
+	"io"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 )
@@ -28,6 +29,88 @@ type MakeEnvironment func(context.Context, *Request) (Environment, error)
 // The user can define a function like this to pass it to cli.Run.
 type MakeExecutor func(*Request, interface{}) (Executor, error)
 
+// Middleware wraps an Executor to run code around the execution of a command,
+// e.g. for authentication, metrics or logging. It may also replace the
+// ResponseEmitter that is passed on to next. Middleware runs where the command
+// runs: in the Executor of NewExecutor, in Command.Call and thus in the HTTP
+// handler, but not in the HTTP client, so that it runs once per request.
+type Middleware func(next Executor) Executor
+
+// ExecutorFunc is an adapter to allow the use of ordinary functions as
+// Executors.
+type ExecutorFunc func(req *Request, re ResponseEmitter, env Environment) error
+
+// Execute calls f(req, re, env).
+func (f ExecutorFunc) Execute(req *Request, re ResponseEmitter, env Environment) error {
+	return f(req, re, env)
+}
+
+// Chain wraps x with the given middlewares. The first middleware is the
+// outermost one, i.e. it is called first and sees the result of all others.
+func Chain(x Executor, mws ...Middleware) Executor {
+	for i := len(mws) - 1; i >= 0; i-- {
+		x = mws[i](x)
+	}
+
+	return x
+}
+
+// SetEncoder sets the encoder of re to the one registered for encType if re
+// encodes messages (e.g. http, cli or writer - but not chan). The command's
+// Encoders take precedence over the global ones; if neither has an encoder
+// for encType, JSON is used.
+func SetEncoder(req *Request, re ResponseEmitter, encType EncodingType) {
+	ee, ok := re.(EncodingEmitter)
+	if !ok {
+		return
+	}
+
+	var enc func(io.Writer) Encoder
+	if mkEnc, ok := req.Command.Encoders[encType]; ok {
+		enc = mkEnc(req)
+	} else if mkEnc, ok := Encoders[encType]; ok {
+		enc = mkEnc(req)
+	} else {
+		log.Errorf("unknown encoding %q, using json", encType)
+		enc = Encoders[JSON](req)
+	}
+
+	ee.SetEncoder(traceEncoder(req.Context, enc))
+}
+
+// ApplyPostRun returns the emitter built by the command's PostRun function for
+// the PostRunType of re, or re itself if there is none.
+func ApplyPostRun(req *Request, re ResponseEmitter) ResponseEmitter {
+	cmd := req.Command
+	if cmd.PostRun == nil {
+		return re
+	}
+
+	if typer, ok := re.(interface {
+		Type() PostRunType
+	}); ok && cmd.PostRun[typer.Type()] != nil {
+		if !tracing(req.Context) {
+			return forwardPostRun(cmd.PostRun[typer.Type()](req, re), re)
+		}
+
+		// the span ends when the PostRun emitter is closed
+		req, span := req.WithSpan("cmds.postrun")
+		return &spanCloser{ResponseEmitter: forwardPostRun(cmd.PostRun[typer.Type()](req, re), re), span: span}
+	}
+
+	return re
+}
+
+// forwardPostRun passes the progress and warnings of the command on to re if
+// the PostRun emitter is one of a chan response pair, since a PostRun reading
+// its response with Next never sees them.
+func forwardPostRun(postRe, re ResponseEmitter) ResponseEmitter {
+	if cre, ok := postRe.(*chanResponseEmitter); ok {
+		cre.forwardTo(re)
+	}
+	return postRe
+}
+
 func NewExecutor(root *Command) Executor {
 	return &executor{
 		root: root,
@@ -38,7 +121,29 @@ type executor struct {
 	root *Command
 }
 
-func (x *executor) Execute(req *Request, re ResponseEmitter, env Environment) (err error) {
+func (x *executor) Execute(req *Request, re ResponseEmitter, env Environment) error {
+	mws, err := x.root.GetMiddleware(req.Path)
+	if err != nil {
+		return err
+	}
+
+	req, cancel, err := req.WithTimeout()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	req, span := req.WithSpan("cmds.execute")
+	defer span.Finish()
+
+	err = Chain(ExecutorFunc(x.execute), mws...).Execute(req, re, env)
+	if err != nil {
+		span.SetError(err)
+	}
+	return err
+}
+
+func (x *executor) execute(req *Request, re ResponseEmitter, env Environment) (err error) {
 	cmd := req.Command
 
 	if cmd.Run == nil {
@@ -50,41 +155,22 @@ func (x *executor) Execute(req *Request, re ResponseEmitter, env Environment) (e
 		return err
 	}
 
-	// If this ResponseEmitter encodes messages (e.g. http, cli or writer - but not chan),
-	// we need to update the encoding to the one specified by the command.
-	if ee, ok := re.(EncodingEmitter); ok {
-		encType := GetEncoding(req)
+	encType := GetEncoding(req)
 
-		// use JSON if text was requested but the command doesn't have a text-encoder
-		if _, ok := cmd.Encoders[encType]; encType == Text && !ok {
-			encType = JSON
-		}
-
-		if enc, ok := cmd.Encoders[encType]; ok {
-			ee.SetEncoder(enc(req))
-		} else if enc, ok := Encoders[encType]; ok {
-			ee.SetEncoder(enc(req))
-		} else {
-			log.Errorf("unknown encoding %q, using json", encType)
-			ee.SetEncoder(Encoders[JSON](req))
-		}
+	// use JSON if text was requested but the command doesn't have a text-encoder
+	if _, ok := cmd.Encoders[encType]; encType == Text && !ok {
+		encType = JSON
 	}
 
-	if cmd.PreRun != nil {
-		err = cmd.PreRun(req, env)
-		if err != nil {
-			return err
-		}
-	}
+	SetEncoder(req, re, encType)
 
-	if cmd.PostRun != nil {
-		if typer, ok := re.(interface {
-			Type() PostRunType
-		}); ok && cmd.PostRun[typer.Type()] != nil {
-			re = cmd.PostRun[typer.Type()](req, re)
-		}
+	err = RunPreRun(req, env)
+	if err != nil {
+		return err
 	}
 
+	re = ApplyPostRun(req, re)
+
 	defer func() {
 		re.Close()
 	}()
@@ -105,6 +191,26 @@ func (x *executor) Execute(req *Request, re ResponseEmitter, env Environment) (e
 		}
 
 	}()
-	cmd.Run(req, re, env)
+	runReq, span := req.WithSpan("cmds.run")
+	defer span.Finish()
+
+	cmd.Run(runReq, re, env)
 	return nil
 }
+
+// RunPreRun calls the PreRun function of the command of req, if any, in a
+// span.
+func RunPreRun(req *Request, env Environment) error {
+	if req.Command.PreRun == nil {
+		return nil
+	}
+
+	req, span := req.WithSpan("cmds.prerun")
+	defer span.Finish()
+
+	err := req.Command.PreRun(req, env)
+	if err != nil {
+		span.SetError(err)
+	}
+	return err
+}
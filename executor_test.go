@@ -3,6 +3,7 @@ package cmds
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"testing"
 )
@@ -45,3 +46,92 @@ func TestExecutor(t *testing.T) {
 		t.Errorf("expected output \"42\" but got %q", out)
 	}
 }
+
+func TestExecutorMiddleware(t *testing.T) {
+	var calls []string
+	mark := func(name string) Middleware {
+		return func(next Executor) Executor {
+			return ExecutorFunc(func(req *Request, re ResponseEmitter, env Environment) error {
+				calls = append(calls, name)
+				return next.Execute(req, re, env)
+			})
+		}
+	}
+
+	root := &Command{
+		Middleware: []Middleware{mark("root1"), mark("root2")},
+		Subcommands: map[string]*Command{
+			"test": &Command{
+				Middleware: []Middleware{mark("test")},
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					calls = append(calls, "run")
+					re.Emit("ok")
+				},
+			},
+		},
+	}
+
+	expected := []string{"root1", "root2", "test", "run"}
+	check := func(what string) {
+		if len(calls) != len(expected) {
+			t.Fatalf("%s: expected calls %v, got %v", what, expected, calls)
+		}
+		for i := range calls {
+			if calls[i] != expected[i] {
+				t.Fatalf("%s: expected calls %v, got %v", what, expected, calls)
+			}
+		}
+		calls = nil
+	}
+
+	req, err := NewRequest(context.Background(), []string{"test"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	re := NewWriterResponseEmitter(wc{&buf, nopCloser{}}, req, Encoders[Text])
+	err = NewExecutor(root).Execute(req, re, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("Execute")
+
+	buf.Reset()
+	re = NewWriterResponseEmitter(wc{&buf, nopCloser{}}, req, Encoders[Text])
+	root.Call(req, re, nil)
+	check("Call")
+}
+
+func TestExecutorMiddlewareAbort(t *testing.T) {
+	errDenied := errors.New("denied")
+
+	root := &Command{
+		Middleware: []Middleware{
+			func(next Executor) Executor {
+				return ExecutorFunc(func(req *Request, re ResponseEmitter, env Environment) error {
+					return errDenied
+				})
+			},
+		},
+		Subcommands: map[string]*Command{
+			"test": &Command{
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					t.Error("Run should not have been called")
+				},
+			},
+		},
+	}
+
+	req, err := NewRequest(context.Background(), []string{"test"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	re := NewWriterResponseEmitter(wc{&buf, nopCloser{}}, req, Encoders[Text])
+	err = NewExecutor(root).Execute(req, re, nil)
+	if err != errDenied {
+		t.Fatalf("expected error %q, got %v", errDenied, err)
+	}
+}
@@ -18,6 +18,10 @@ func (ff *flushfwder) Close() error {
 	return ff.ResponseEmitter.Close()
 }
 
+func (ff *flushfwder) Warn(msg string) error {
+	return Warn(ff.ResponseEmitter, msg)
+}
+
 func NewFlushForwarder(re ResponseEmitter, f Flusher) ResponseEmitter {
 	return &flushfwder{ResponseEmitter: re, Flusher: f}
 }
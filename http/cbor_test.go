@@ -0,0 +1,81 @@
+package http
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+type cborNode struct {
+	Name  string
+	Links []string
+	Data  []byte
+}
+
+func TestCBOR(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"nodes": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					re.Emit(&cborNode{Name: "a", Links: []string{"b"}, Data: []byte{0, 1}})
+					re.Emit(&cborNode{Name: "b"})
+					re.SetError("boom", cmdkit.ErrNormal)
+				},
+				Type: cborNode{},
+			},
+			"fail": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					re.SetError("not found", cmdkit.ErrClient)
+				},
+				Type: cborNode{},
+			},
+		},
+	}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, ClientWithEncoding(cmds.CBOR))
+	send := func(path string) cmds.Response {
+		req, err := cmds.NewRequest(context.Background(), []string{path}, nil, nil, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := client.Send(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := send("nodes")
+	var nodes []cborNode
+	err := cmds.Collect(res, &nodes)
+	if e, ok := err.(*cmdkit.Error); !ok || e.Message != "boom" {
+		t.Errorf("expected the command error, got %#v", err)
+	}
+	exp := []cborNode{
+		{Name: "a", Links: []string{"b"}, Data: []byte{0, 1}},
+		{Name: "b"},
+	}
+	if !reflect.DeepEqual(nodes, exp) {
+		t.Errorf("expected %v, got %v", exp, nodes)
+	}
+	if _, ok := res.(*Response); !ok {
+		t.Fatalf("expected *Response, got %T", res)
+	}
+	if ct := res.(*Response).res.Header.Get(contentTypeHeader); ct != "application/cbor" {
+		t.Errorf("expected content type application/cbor, got %q", ct)
+	}
+
+	var n cborNode
+	err = cmds.ReadOnce(send("fail"), &n)
+	if e, ok := err.(*cmdkit.Error); !ok || e.Message != "not found" || e.Code != cmdkit.ErrClient {
+		t.Errorf("expected the command error, got %#v", err)
+	}
+}
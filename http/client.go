@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmdkit/files"
@@ -37,6 +38,7 @@ type client struct {
 	httpClient    *http.Client
 	ua            string
 	apiPrefix     string
+	encoding      cmds.EncodingType
 }
 
 type ClientOpt func(*client)
@@ -53,6 +55,15 @@ func ClientWithAPIPrefix(apiPrefix string) ClientOpt {
 	}
 }
 
+// ClientWithEncoding sets the encoding the server sends the values in, JSON
+// by default, e.g. cmds.CBOR. With cmds.Protobuf, commands whose Type is not
+// a message, see cmds.IsProtoMessage, still use JSON.
+func ClientWithEncoding(enc cmds.EncodingType) ClientOpt {
+	return func(c *client) {
+		c.encoding = enc
+	}
+}
+
 func NewClient(address string, opts ...ClientOpt) Client {
 	if !strings.HasPrefix(address, "http://") {
 		address = "http://" + address
@@ -62,6 +73,7 @@ func NewClient(address string, opts ...ClientOpt) Client {
 		serverAddress: address,
 		httpClient:    http.DefaultClient,
 		ua:            "go-ipfs-cmds/http",
+		encoding:      cmds.JSON,
 	}
 
 	for _, opt := range opts {
@@ -71,40 +83,35 @@ func NewClient(address string, opts ...ClientOpt) Client {
 	return c
 }
 
+// Execute sends req to the server and copies the response to re. The
+// middleware of the commands is not run, the handler runs it on the server.
 func (c *client) Execute(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-	cmd := req.Command
-
-	// If this ResponseEmitter encodes messages (e.g. http, cli or writer - but not chan),
-	// we need to update the encoding to the one specified by the command.
-	if ee, ok := re.(cmds.EncodingEmitter); ok {
-		encType := cmds.GetEncoding(req)
-
-		// note the difference: cmd.Encoders vs. cmds.Encoders
-		if enc, ok := cmd.Encoders[encType]; ok {
-			ee.SetEncoder(enc(req))
-		} else if enc, ok := cmds.Encoders[encType]; ok {
-			ee.SetEncoder(enc(req))
-		} else {
-			log.Errorf("unknown encoding %q, using json", encType)
-			ee.SetEncoder(cmds.Encoders[cmds.JSON](req))
-		}
+	req, cancel, err := req.WithTimeout()
+	if err != nil {
+		return err
 	}
+	defer cancel()
 
-	if cmd.PreRun != nil {
-		err := cmd.PreRun(req, env)
-		if err != nil {
-			return err
-		}
+	req, span := req.WithSpan("cmds.execute")
+	defer span.Finish()
+
+	err = c.execute(req, re, env)
+	if err != nil {
+		span.SetError(err)
 	}
+	return err
+}
 
-	if cmd.PostRun != nil {
-		if typer, ok := re.(interface {
-			Type() cmds.PostRunType
-		}); ok && cmd.PostRun[typer.Type()] != nil {
-			re = cmd.PostRun[typer.Type()](req, re)
-		}
+func (c *client) execute(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+	cmds.SetEncoder(req, re, cmds.GetEncoding(req))
+
+	err := cmds.RunPreRun(req, env)
+	if err != nil {
+		return err
 	}
 
+	re = cmds.ApplyPostRun(req, re)
+
 	res, err := c.Send(req)
 	if err != nil {
 		if isConnRefused(err) {
@@ -116,6 +123,17 @@ func (c *client) Execute(req *cmds.Request, re cmds.ResponseEmitter, env cmds.En
 	return cmds.Copy(re, res)
 }
 
+// wireEncoding returns the encoding the server should send the values of req
+// in.
+func (c *client) wireEncoding(req *cmds.Request) cmds.EncodingType {
+	if c.encoding == cmds.Protobuf {
+		if !cmds.IsProtoMessage(req.Command.Type) {
+			return cmds.JSON
+		}
+	}
+	return c.encoding
+}
+
 func (c *client) Send(req *cmds.Request) (cmds.Response, error) {
 	if req.Context == nil {
 		log.Warningf("no context set in request")
@@ -125,8 +143,8 @@ func (c *client) Send(req *cmds.Request) (cmds.Response, error) {
 	// save user-provided encoding
 	previousUserProvidedEncoding, found := req.Options[cmds.EncLong].(string)
 
-	// override with json to send to server
-	req.SetOption(cmds.EncLong, cmds.JSON)
+	// override with the wire encoding to send to server
+	req.SetOption(cmds.EncLong, c.wireEncoding(req))
 
 	// stream channel output
 	req.SetOption(cmds.ChanOpt, true)
@@ -153,6 +171,12 @@ func (c *client) Send(req *cmds.Request) (cmds.Response, error) {
 	path := strings.Join(req.Path, "/")
 	url := fmt.Sprintf(ApiUrlFormat, c.serverAddress, c.apiPrefix, path, query)
 
+	// the span ends when the server has responded, not when the response is
+	// read
+	_, span := cmds.StartSpan(req.Context, "http.client")
+	span.SetAttribute("command", path)
+	defer span.Finish()
+
 	httpReq, err := http.NewRequest("POST", url, reader)
 	if err != nil {
 		return nil, err
@@ -165,18 +189,21 @@ func (c *client) Send(req *cmds.Request) (cmds.Response, error) {
 		httpReq.Header.Set(contentTypeHeader, applicationOctetStream)
 	}
 	httpReq.Header.Set(uaHeader, c.ua)
+	httpReq.Header.Set(traceparentHeader, span.Traceparent())
 
 	httpReq = httpReq.WithContext(req.Context)
 	httpReq.Close = true
 
 	httpRes, err := c.httpClient.Do(httpReq)
 	if err != nil {
+		span.SetError(err)
 		return nil, err
 	}
 
-	// using the overridden JSON encoding in request
+	// using the overridden encoding in request
 	res, err := parseResponse(httpRes, req)
 	if err != nil {
+		span.SetError(err)
 		return nil, err
 	}
 
@@ -201,6 +228,15 @@ func getQuery(req *cmds.Request) (string, error) {
 		query.Set(k, str)
 	}
 
+	// let the server stop when we stop waiting
+	if deadline, ok := req.Context.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining < time.Millisecond {
+			remaining = time.Millisecond
+		}
+		query.Set(cmds.TimeoutOpt, remaining.String())
+	}
+
 	args := req.Arguments
 	argDefs := req.Command.Arguments
 
@@ -1,9 +1,12 @@
 package http
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -91,3 +94,60 @@ func TestClientAPIPrefix(t *testing.T) {
 		}
 	}
 }
+
+func TestClientMiddleware(t *testing.T) {
+	var calls []string
+	var mu sync.Mutex
+	mark := func(next cmds.Executor) cmds.Executor {
+		return cmds.ExecutorFunc(func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+			mu.Lock()
+			calls = append(calls, strings.Join(req.Path, " "))
+			mu.Unlock()
+			return next.Execute(req, re, env)
+		})
+	}
+
+	// the client and the server share the root, like a CLI talking to its daemon
+	root := &cmds.Command{
+		Middleware: []cmds.Middleware{mark},
+		Subcommands: map[string]*cmds.Command{
+			"echo": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					cmds.EmitOnce(re, "hello")
+				},
+				Type: "",
+			},
+		},
+	}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	req, err := cmds.NewRequest(context.Background(), []string{"echo"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	re, res := cmds.NewChanResponsePair(req)
+	go func() {
+		if err := NewClient(srv.URL).(cmds.Executor).Execute(req, re, nil); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var s string
+	if err := cmds.ReadOnce(res, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "hello" {
+		t.Errorf("expected %q, got %q", "hello", s)
+	}
+
+	// the middleware runs once, on the server
+	mu.Lock()
+	defer mu.Unlock()
+	if exp := []string{"echo"}; !reflect.DeepEqual(calls, exp) {
+		t.Errorf("expected middleware calls %v, got %v", exp, calls)
+	}
+}
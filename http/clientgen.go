@@ -0,0 +1,416 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// reservedParams are the identifiers used in the body of the generated
+// methods, which parameters must not shadow.
+var reservedParams = []string{"c", "ctx", "opts", "f", "args", "optMap", "res", "err", "append", "nil", "string"}
+
+// optionTypes maps the kinds of options to the Go types used for them in
+// generated clients.
+var optionTypes = map[reflect.Kind]string{
+	reflect.Bool:    "bool",
+	reflect.Int:     "int",
+	reflect.Uint:    "uint",
+	reflect.Int64:   "int64",
+	reflect.Uint64:  "uint64",
+	reflect.Float64: "float64",
+	reflect.String:  "string",
+}
+
+// GenerateClient writes the source of a Go package named pkgName with typed
+// bindings for the callable commands below root. The generated Client has one
+// method per command, named after its path in CamelCase, that takes the
+// command's arguments and options as typed parameters and returns an iterator
+// over values of the command's Type. It is built on top of Client and needs
+// root at runtime to build the requests.
+func GenerateClient(w io.Writer, pkgName string, root *cmds.Command) error {
+	g := &clientGen{
+		imports: map[string]string{
+			"context":                              "context",
+			"github.com/ipfs/go-ipfs-cmdkit/files": "files",
+			"github.com/ipfs/go-ipfs-cmds":         "cmds",
+			"github.com/ipfs/go-ipfs-cmds/http":    "cmdshttp",
+		},
+		methods: make(map[string][]string),
+	}
+
+	for _, ci := range cmds.ExportCommands(root) {
+		if len(ci.Path) == 0 || !ci.Callable {
+			continue
+		}
+
+		err := g.command(root, ci.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	if g.usesFmt {
+		g.imports["fmt"] = "fmt"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by go-ipfs-cmds/http.GenerateClient. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkgName)
+
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	fmt.Fprintf(&buf, "import (\n")
+	for _, p := range paths {
+		if g.imports[p] == path.Base(p) {
+			fmt.Fprintf(&buf, "\t%q\n", p)
+		} else {
+			fmt.Fprintf(&buf, "\t%s %q\n", g.imports[p], p)
+		}
+	}
+	fmt.Fprintf(&buf, ")\n\n")
+
+	buf.WriteString(clientGenHeader)
+	buf.Write(g.body.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("generated invalid code: %s", err)
+	}
+
+	_, err = w.Write(src)
+	return err
+}
+
+const clientGenHeader = `// Client calls commands on a remote API with typed parameters and results.
+type Client struct {
+	client cmdshttp.Client
+	root   *cmds.Command
+}
+
+// NewClient returns a Client that sends requests for the commands of root
+// using c.
+func NewClient(c cmdshttp.Client, root *cmds.Command) *Client {
+	return &Client{client: c, root: root}
+}
+
+func (c *Client) send(ctx context.Context, path []string, opts map[string]interface{}, args []string, f files.File) (cmds.Response, error) {
+	req, err := cmds.NewRequest(ctx, path, opts, args, f, c.root)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.client.Send(req)
+}
+
+// Bool returns a pointer to v, for use in options.
+func Bool(v bool) *bool { return &v }
+
+// Int returns a pointer to v, for use in options.
+func Int(v int) *int { return &v }
+
+// Uint returns a pointer to v, for use in options.
+func Uint(v uint) *uint { return &v }
+
+// Int64 returns a pointer to v, for use in options.
+func Int64(v int64) *int64 { return &v }
+
+// Uint64 returns a pointer to v, for use in options.
+func Uint64(v uint64) *uint64 { return &v }
+
+// Float64 returns a pointer to v, for use in options.
+func Float64(v float64) *float64 { return &v }
+
+// String returns a pointer to v, for use in options.
+func String(v string) *string { return &v }
+`
+
+type clientGen struct {
+	// imports maps import paths to their names
+	imports map[string]string
+	// methods maps method names to the command paths they call
+	methods map[string][]string
+	usesFmt bool
+	body    bytes.Buffer
+}
+
+// command writes the method, options and response types for the command at
+// pth.
+func (g *clientGen) command(root *cmds.Command, pth []string) error {
+	cmdsAtPath, err := root.Resolve(pth)
+	if err != nil {
+		return err
+	}
+	cmd := cmdsAtPath[len(cmdsAtPath)-1]
+
+	name := exportedName(pth...)
+	if other, ok := g.methods[name]; ok {
+		return fmt.Errorf("commands %q and %q both map to method %s", strings.Join(other, " "), strings.Join(pth, " "), name)
+	}
+	g.methods[name] = pth
+
+	w := &g.body
+
+	// options
+	type optField struct {
+		field, name, typ string
+	}
+	var fields []optField
+	seen := make(map[string]string)
+	for _, c := range cmdsAtPath {
+		for _, opt := range c.Options {
+			optName := opt.Names()[0]
+			if optName == cmds.EncLong || optName == cmds.ChanOpt || OptionSkipMap[optName] {
+				// set by the client itself
+				continue
+			}
+
+			typ, ok := optionTypes[opt.Type()]
+			if !ok {
+				return fmt.Errorf("option %q of command %q has unsupported type %s", optName, strings.Join(pth, " "), opt.Type())
+			}
+
+			field := exportedName(optName)
+			if other, ok := seen[field]; ok {
+				return fmt.Errorf("options %q and %q of command %q both map to field %s", other, optName, strings.Join(pth, " "), field)
+			}
+			seen[field] = optName
+
+			fields = append(fields, optField{field, optName, typ})
+		}
+	}
+
+	fmt.Fprintf(w, "// %sOptions are the options of %s. Options that are nil are not sent.\n", name, strings.Join(pth, " "))
+	fmt.Fprintf(w, "type %sOptions struct {\n", name)
+	for _, f := range fields {
+		fmt.Fprintf(w, "\t%s *%s\n", f.field, f.typ)
+	}
+	fmt.Fprintf(w, "}\n\n")
+
+	// response
+	resType, err := g.resultType(cmd.Type)
+	if err != nil {
+		return fmt.Errorf("command %q: %s", strings.Join(pth, " "), err)
+	}
+
+	fmt.Fprintf(w, "// %sResponse is the response of %s.\n", name, strings.Join(pth, " "))
+	fmt.Fprintf(w, "type %sResponse struct {\n\tcmds.Response\n}\n\n", name)
+	if resType != "" {
+		g.usesFmt = true
+		fmt.Fprintf(w, "// Next returns the next value emitted by the command. It returns io.EOF\n")
+		fmt.Fprintf(w, "// after the last value and cmds.ErrRcvdError if the command failed, see Error.\n")
+		fmt.Fprintf(w, "func (r *%sResponse) Next() (%s, error) {\n", name, resType)
+		fmt.Fprintf(w, "\tv, err := r.Response.Next()\n")
+		fmt.Fprintf(w, "\tif err != nil {\n\t\treturn nil, err\n\t}\n\n")
+		fmt.Fprintf(w, "\tout, ok := v.(%s)\n", resType)
+		fmt.Fprintf(w, "\tif !ok {\n\t\treturn nil, fmt.Errorf(\"unexpected type: %%T\", v)\n\t}\n")
+		fmt.Fprintf(w, "\treturn out, nil\n}\n\n")
+	}
+
+	// method
+	params := []string{"ctx context.Context"}
+	var argCode []string
+	hasFiles := false
+	used := make(map[string]bool)
+	for _, id := range reservedParams {
+		used[id] = true
+	}
+	for _, arg := range cmd.Arguments {
+		if arg.Type == cmdkit.ArgFile {
+			hasFiles = true
+			continue
+		}
+
+		param := paramName(arg.Name)
+		for used[param] {
+			param += "_"
+		}
+		used[param] = true
+
+		switch {
+		case arg.Variadic:
+			params = append(params, param+" []string")
+			argCode = append(argCode, fmt.Sprintf("args = append(args, %s...)", param))
+		case arg.Required:
+			params = append(params, param+" string")
+			argCode = append(argCode, fmt.Sprintf("args = append(args, %s)", param))
+		default:
+			params = append(params, param+" string")
+			argCode = append(argCode, fmt.Sprintf("if %s != \"\" {\n\t\targs = append(args, %s)\n\t}", param, param))
+		}
+	}
+	if hasFiles {
+		params = append(params, "f files.File")
+	}
+	params = append(params, fmt.Sprintf("opts *%sOptions", name))
+
+	var tagline string
+	if cmd.Helptext.Tagline != "" {
+		tagline = " " + cmd.Helptext.Tagline
+	}
+	fmt.Fprintf(w, "// %s calls %s.%s\n", name, strings.Join(pth, " "), tagline)
+	fmt.Fprintf(w, "func (c *Client) %s(%s) (*%sResponse, error) {\n", name, strings.Join(params, ", "), name)
+	fmt.Fprintf(w, "\targs := []string{}\n")
+	for _, code := range argCode {
+		fmt.Fprintf(w, "\t%s\n", code)
+	}
+	fmt.Fprintf(w, "\n\toptMap := map[string]interface{}{}\n")
+	if len(fields) > 0 {
+		fmt.Fprintf(w, "\tif opts != nil {\n")
+		for _, f := range fields {
+			fmt.Fprintf(w, "\t\tif opts.%s != nil {\n\t\t\toptMap[%q] = *opts.%s\n\t\t}\n", f.field, f.name, f.field)
+		}
+		fmt.Fprintf(w, "\t}\n")
+	}
+
+	file := "nil"
+	if hasFiles {
+		file = "f"
+	}
+	fmt.Fprintf(w, "\n\tres, err := c.send(ctx, %#v, optMap, args, %s)\n", pth, file)
+	fmt.Fprintf(w, "\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+	fmt.Fprintf(w, "\treturn &%sResponse{res}, nil\n}\n\n", name)
+
+	return nil
+}
+
+// resultType returns the type of the values returned by Response.Next for
+// commands of the given Type, or the empty string if they are not typed.
+func (g *clientGen) resultType(typ interface{}) (string, error) {
+	t := reflect.TypeOf(typ)
+	if t == nil {
+		return "", nil
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface {
+		return "", nil
+	}
+
+	expr, err := g.typeExpr(t)
+	if err != nil {
+		return "", err
+	}
+	return "*" + expr, nil
+}
+
+// typeExpr returns the Go expression for t, adding imports as needed.
+func (g *clientGen) typeExpr(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name(), nil
+		}
+		if t.PkgPath() == "main" {
+			return "", fmt.Errorf("type %s is declared in package main", t)
+		}
+		return g.importName(t.PkgPath()) + "." + t.Name(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem, err := g.typeExpr(t.Elem())
+		return "*" + elem, err
+	case reflect.Slice:
+		elem, err := g.typeExpr(t.Elem())
+		return "[]" + elem, err
+	case reflect.Array:
+		elem, err := g.typeExpr(t.Elem())
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), err
+	case reflect.Map:
+		key, err := g.typeExpr(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := g.typeExpr(t.Elem())
+		return fmt.Sprintf("map[%s]%s", key, elem), err
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported type %s", t)
+}
+
+// importName returns the name under which the package at pkgPath is imported,
+// picking a unique one if necessary.
+func (g *clientGen) importName(pkgPath string) string {
+	if name, ok := g.imports[pkgPath]; ok {
+		return name
+	}
+
+	base := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, strings.TrimPrefix(path.Base(pkgPath), "go-"))
+	if base == "" || !unicode.IsLetter(rune(base[0])) {
+		base = "pkg" + base
+	}
+
+	// fmt is imported later if needed
+	taken := map[string]bool{"fmt": true}
+	for _, name := range g.imports {
+		taken[name] = true
+	}
+
+	name := base
+	for i := 2; taken[name] || token.Lookup(name).IsKeyword(); i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+
+	g.imports[pkgPath] = name
+	return name
+}
+
+// exportedName joins parts in CamelCase, treating dashes and underscores as
+// word separators, e.g. "set-keep-time" becomes SetKeepTime.
+func exportedName(parts ...string) string {
+	var out []rune
+	for _, part := range parts {
+		upper := true
+		for _, r := range part {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				upper = true
+				continue
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			out = append(out, r)
+		}
+	}
+	if len(out) == 0 || !unicode.IsLetter(out[0]) {
+		out = append([]rune("X"), out...)
+	}
+	return string(out)
+}
+
+// paramName returns the name of the parameter for the argument called name.
+func paramName(name string) string {
+	exp := []rune(exportedName(name))
+	exp[0] = unicode.ToLower(exp[0])
+
+	param := string(exp)
+	if token.Lookup(param).IsKeyword() {
+		param += "Arg"
+	}
+	return param
+}
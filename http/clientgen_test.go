@@ -0,0 +1,109 @@
+package http
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestGenerateClient(t *testing.T) {
+	run := func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {}
+	root := &cmds.Command{
+		Options: []cmdkit.Option{
+			cmds.OptionEncodingType,
+			cmds.OptionStreamChannels,
+			cmds.OptionTimeout,
+		},
+		Subcommands: map[string]*cmds.Command{
+			// types of test files can't be imported by the generated code
+			"version": &cmds.Command{
+				Options: cmdRoot.Subcommands["version"].Options,
+				Run:     run,
+				Type:    cmdkit.Argument{},
+			},
+			"block": &cmds.Command{
+				Subcommands: map[string]*cmds.Command{
+					"put": &cmds.Command{
+						Helptext: cmdkit.HelpText{Tagline: "Store a block."},
+						Arguments: []cmdkit.Argument{
+							cmdkit.FileArg("data", true, false, "The data."),
+						},
+						Options: []cmdkit.Option{
+							cmdkit.StringOption("format", "f", "The format."),
+							cmdkit.IntOption("mh-len", "The hash length."),
+						},
+						Run:  run,
+						Type: map[string][]cmds.Progress{},
+					},
+					"get": &cmds.Command{
+						Arguments: []cmdkit.Argument{
+							cmdkit.StringArg("type", true, false, "The type."),
+							cmdkit.StringArg("keys", true, true, "The keys."),
+						},
+						Run: run,
+					},
+					"stat": &cmds.Command{
+						Arguments: []cmdkit.Argument{
+							cmdkit.StringArg("c", true, false, "Shadows the receiver."),
+							cmdkit.StringArg("opt-map", true, false, "Shadows a local."),
+							cmdkit.StringArg("append", false, true, "Shadows a builtin."),
+						},
+						Run: run,
+					},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateClient(&buf, "api", root); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "api.go", src, 0)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %s\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("api", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("generated code does not type-check: %s\n%s", err, src)
+	}
+
+	expected := []string{
+		"package api",
+		`cmdshttp "github.com/ipfs/go-ipfs-cmds/http"`,
+		"func (c *Client) Version(ctx context.Context, opts *VersionOptions) (*VersionResponse, error)",
+		"func (r *VersionResponse) Next() (*ipfscmdkit.Argument, error)",
+		"func (c *Client) BlockPut(ctx context.Context, f files.File, opts *BlockPutOptions) (*BlockPutResponse, error)",
+		"func (r *BlockPutResponse) Next() (*map[string][]cmds.Progress, error)",
+		"func (c *Client) BlockGet(ctx context.Context, typeArg string, keys []string, opts *BlockGetOptions) (*BlockGetResponse, error)",
+		"MhLen *int",
+		`optMap["mh-len"] = *opts.MhLen`,
+		"Timeout *string",
+		`[]string{"block", "put"}`,
+		"func (c *Client) BlockStat(ctx context.Context, c_ string, optMap_ string, append_ []string, opts *BlockStatOptions) (*BlockStatResponse, error)",
+	}
+	// ignore alignment by gofmt
+	normalized := strings.Join(strings.Fields(src), " ")
+	for _, exp := range expected {
+		if !strings.Contains(normalized, exp) {
+			t.Errorf("generated code does not contain %q:\n%s", exp, src)
+		}
+	}
+
+	for _, unexp := range []string{"Encoding *string", "StreamChannels", "func (r *BlockGetResponse) Next"} {
+		if strings.Contains(src, unexp) {
+			t.Errorf("generated code should not contain %q", unexp)
+		}
+	}
+}
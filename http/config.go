@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"sync"
 
+	cmds "github.com/ipfs/go-ipfs-cmds"
 	cors "github.com/rs/cors"
 )
 
@@ -22,6 +23,43 @@ type ServerConfig struct {
 	// Headers is an optional map of headers that is written out.
 	Headers map[string][]string
 
+	// OpenAPIPath is the path below APIPath at which the OpenAPI document of
+	// the command tree is served, e.g. /openapi.json. It is not served if
+	// empty.
+	OpenAPIPath string
+
+	// OpenAPIInfo is the metadata included in the served OpenAPI document.
+	OpenAPIInfo OpenAPIInfo
+
+	// ConcurrencyLimit restricts the number of requests run at the same time
+	// across all commands.
+	ConcurrencyLimit ConcurrencyLimit
+
+	// CommandLimits restricts the number of requests run at the same time
+	// per command, keyed by the path of the command joined by slashes, e.g.
+	// "block/put".
+	CommandLimits map[string]ConcurrencyLimit
+
+	// RateLimit restricts the rate of requests per client across all
+	// commands, CommandRateLimits per command, keyed like CommandLimits.
+	RateLimit         RateLimit
+	CommandRateLimits map[string]RateLimit
+
+	// ClientIdentity returns the identity of the client making a request for
+	// rate limiting, e.g. an authenticated user name. It defaults to
+	// RemoteAddrIdentity.
+	ClientIdentity func(*http.Request) string
+
+	// Metrics records all requests handled, including the ones rejected
+	// because of rate or concurrency limits. Serve them with
+	// NewMetricsHandler. If the root command also uses the Middleware of
+	// Metrics, it skips the requests recorded here.
+	Metrics *cmds.Metrics
+
+	// SpanExporter receives the spans of all requests handled. Requests
+	// continue the trace sent by the client in the traceparent header.
+	SpanExporter cmds.SpanExporter
+
 	// corsOpts is a set of options for CORS headers.
 	corsOpts *cors.Options
 
@@ -10,6 +10,10 @@ type flushfwder struct {
 	http.Flusher
 }
 
+func (ff flushfwder) Warn(msg string) error {
+	return cmds.Warn(ff.ResponseEmitter, msg)
+}
+
 func NewFlushForwarder(r cmds.ResponseEmitter, f http.Flusher) ResponseEmitter {
 	return flushfwder{ResponseEmitter: r, Flusher: f}
 }
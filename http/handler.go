@@ -6,8 +6,8 @@ import (
 	"net/http"
 	"runtime/debug"
 	"strings"
-	"time"
 
+	"github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	logging "github.com/ipfs/go-log"
 	"github.com/libp2p/go-libp2p-loggables"
@@ -23,6 +23,7 @@ var (
 
 const (
 	StreamErrHeader          = "X-Stream-Error"
+	StreamWarningHeader      = "X-Stream-Warning"
 	streamHeader             = "X-Stream-Output"
 	channelHeader            = "X-Chunked-Output"
 	extraContentLengthHeader = "X-Content-Length"
@@ -30,6 +31,10 @@ const (
 	contentTypeHeader        = "Content-Type"
 	contentDispHeader        = "Content-Disposition"
 	transferEncodingHeader   = "Transfer-Encoding"
+	deprecationHeader        = "Deprecation"
+	traceparentHeader        = "Traceparent"
+	traceIDHeader            = "X-Trace-Id"
+	warningHeader            = "Warning"
 	originHeader             = "origin"
 
 	applicationJson        = "application/json"
@@ -52,9 +57,11 @@ func skipAPIHeader(h string) bool {
 
 // the internal handler for the API
 type handler struct {
-	root *cmds.Command
-	cfg  *ServerConfig
-	env  cmds.Environment
+	root   *cmds.Command
+	cfg    *ServerConfig
+	env    cmds.Environment
+	limits *admission
+	rates  *rateLimits
 }
 
 func NewHandler(env cmds.Environment, root *cmds.Command, cfg *ServerConfig) http.Handler {
@@ -67,9 +74,11 @@ func NewHandler(env cmds.Environment, root *cmds.Command, cfg *ServerConfig) htt
 	var h http.Handler
 
 	h = &handler{
-		env:  env,
-		root: root,
-		cfg:  cfg,
+		env:    env,
+		root:   root,
+		cfg:    cfg,
+		limits: newAdmission(cfg),
+		rates:  newRateLimits(cfg),
 	}
 
 	if cfg.APIPath != "" {
@@ -84,6 +93,13 @@ type requestLogger interface {
 	LogRequest(*cmds.Request) func()
 }
 
+// queueLogger is implemented by environments that log requests waiting for
+// admission separately from running ones, e.g. using ReqLog.AddQueued and the
+// Context of the entry. start is called when the request starts running.
+type queueLogger interface {
+	LogQueuedRequest(*cmds.Request) (start func(), done func())
+}
+
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Debug("incoming API request: ", r.URL)
 
@@ -108,6 +124,15 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if h.cfg.OpenAPIPath != "" && r.URL.Path == h.cfg.OpenAPIPath {
+		h.serveOpenAPI(w)
+		return
+	}
+
+	if h.cfg.SpanExporter != nil {
+		ctx = cmds.ContextWithSpanExporter(ctx, h.cfg.SpanExporter)
+	}
+
 	req, err := parseRequest(ctx, r, h.root)
 	if err != nil {
 		if err == ErrNotFound {
@@ -119,32 +144,73 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle the timeout up front.
-	var cancel func()
-	if timeoutStr, ok := req.Options[cmds.TimeoutOpt]; ok {
-		timeout, err := time.ParseDuration(timeoutStr.(string))
-		if err != nil {
-			return
+	path := strings.Join(req.Path, "/")
+
+	var span *cmds.Span
+	req.Context, span = cmds.StartSpan(req.Context, "http.handler")
+	span.SetAttribute("command", path)
+	defer span.Finish()
+	w.Header().Set(traceIDHeader, span.TraceID.String())
+
+	var obs *cmds.Observation
+	if h.cfg.Metrics != nil {
+		obs = h.cfg.Metrics.Start(req)
+		defer obs.Finish()
+
+		// the Middleware of the Metrics must not count the request again
+		req.Context = obs.Context(req.Context)
+	}
+
+	if !h.rates.allow(w, r, path) {
+		log.Debugf("rate limited request for %s", path)
+		if obs != nil {
+			obs.SetError(cmdkit.ErrClient)
 		}
-		req.Context, cancel = context.WithTimeout(req.Context, timeout)
-	} else {
-		req.Context, cancel = context.WithCancel(req.Context)
+		return
 	}
+
+	// the timeout is applied by Call
+	var cancel func()
+	req.Context, cancel = context.WithCancel(req.Context)
 	defer cancel()
 
 	req.Context = logging.ContextWithLoggable(req.Context, loggables.Uuid("requestId"))
+	req.Context = logging.ContextWithLoggable(req.Context, logging.LoggableMap{"traceId": span.TraceID.String()})
 	if cn, ok := w.(http.CloseNotifier); ok {
 		clientGone := cn.CloseNotify()
+		ctx := req.Context
 		go func() {
 			select {
 			case <-clientGone:
-			case <-req.Context.Done():
+			case <-ctx.Done():
 			}
 			cancel()
 		}()
 	}
 
-	if reqLogger, ok := h.env.(requestLogger); ok {
+	if h.limits.limited(path) {
+		start := func() {}
+		if ql, ok := h.env.(queueLogger); ok {
+			var done func()
+			start, done = ql.LogQueuedRequest(req)
+			defer done()
+		} else if reqLogger, ok := h.env.(requestLogger); ok {
+			done := reqLogger.LogRequest(req)
+			defer done()
+		}
+
+		release, rej := h.limits.admit(req.Context, path)
+		if rej != nil {
+			log.Debugf("rejected request for %s: %s", path, rej.msg)
+			if obs != nil {
+				obs.SetError(rej.code())
+			}
+			rej.write(w)
+			return
+		}
+		defer release()
+		start()
+	} else if reqLogger, ok := h.env.(requestLogger); ok {
 		done := reqLogger.LogRequest(req)
 		defer done()
 	}
@@ -156,7 +222,10 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	re := NewResponseEmitter(w, r.Method, req)
+	var re cmds.ResponseEmitter = NewResponseEmitter(w, r.Method, req)
+	if obs != nil {
+		re = obs.Emitter(re)
+	}
 	h.root.Call(req, re, h.env)
 }
 
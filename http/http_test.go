@@ -2,9 +2,16 @@ package http
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 )
 
@@ -52,3 +59,201 @@ func TestHTTP(t *testing.T) {
 		}
 	}
 }
+
+func TestDeprecationHeaders(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"old": &cmds.Command{
+				Deprecated: &cmds.Deprecation{Replacement: "new"},
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					cmds.EmitOnce(re, "ok")
+				},
+				Subcommands: map[string]*cmds.Command{
+					"sub": &cmds.Command{
+						Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+							cmds.EmitOnce(re, "ok")
+						},
+					},
+				},
+			},
+		},
+	}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/old", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if h := res.Header.Get("Deprecation"); h != "true" {
+		t.Errorf("expected Deprecation header to be %q, got %q", "true", h)
+	}
+	if h, exp := res.Header.Get("Warning"), `299 - "command \"old\" is deprecated: use new instead"`; h != exp {
+		t.Errorf("expected Warning header to be %q, got %q", exp, h)
+	}
+
+	// a deprecated parent deprecates its subcommands
+	res, err = http.Post(srv.URL+"/old/sub", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if h := res.Header.Get("Deprecation"); h != "true" {
+		t.Errorf("expected Deprecation header for subcommand to be %q, got %q", "true", h)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	deadlines := make(chan time.Time, 1)
+	root := &cmds.Command{
+		Options: []cmdkit.Option{cmds.OptionTimeout},
+		Subcommands: map[string]*cmds.Command{
+			"wait": &cmds.Command{
+				MaxTimeout: time.Hour,
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					deadline, _ := req.Context.Deadline()
+					deadlines <- deadline
+					cmds.EmitOnce(re, "ok")
+				},
+			},
+		},
+	}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	// the client sends the remaining time of its own deadline
+	req, err := cmds.NewRequest(context.Background(), []string{"wait"}, cmdkit.OptMap{cmds.TimeoutOpt: "1m"}, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	re, res := cmds.NewChanResponsePair(req)
+	go func() {
+		if err := NewClient(srv.URL).(cmds.Executor).Execute(req, re, env); err != nil {
+			t.Error(err)
+		}
+	}()
+	if _, err := res.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := time.Until(<-deadlines); remaining <= 0 || remaining > time.Minute {
+		t.Errorf("expected the server to stop within a minute, got deadline in %s", remaining)
+	}
+
+	// without a timeout the server applies MaxTimeout
+	httpRes, err := http.Post(srv.URL+"/wait", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpRes.Body.Close()
+	if remaining := time.Until(<-deadlines); remaining <= time.Minute || remaining > time.Hour {
+		t.Errorf("expected the server to apply the maximum timeout, got deadline in %s", remaining)
+	}
+
+	httpRes, err = http.Post(srv.URL+"/wait?timeout=soon", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpRes.Body.Close()
+	if httpRes.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid timeout, got %d", http.StatusBadRequest, httpRes.StatusCode)
+	}
+}
+
+type reqLogEnv struct {
+	testEnv
+	log *cmds.ReqLog
+}
+
+func (env reqLogEnv) LogRequest(req *cmds.Request) func() {
+	rle := env.log.Add(req)
+	req.Context = rle.Context()
+	return func() { env.log.Finish(rle) }
+}
+
+func TestReqLogCancel(t *testing.T) {
+	env := reqLogEnv{testEnv{rootCtx: context.Background()}, &cmds.ReqLog{}}
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"slow": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					<-req.Context.Done()
+					re.SetError(req.Context.Err(), cmdkit.ErrNormal)
+				},
+			},
+			"diag": &cmds.Command{
+				Subcommands: map[string]*cmds.Command{
+					"cmds": cmds.ReqLogCommand(env.log),
+				},
+			},
+		},
+	}
+
+	srv := httptest.NewServer(NewHandler(env, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+	c := NewClient(srv.URL)
+
+	send := func(path []string, args ...string) (interface{}, error) {
+		req, err := cmds.NewRequest(context.Background(), path, nil, args, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := c.Send(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v, err := res.Next()
+		if err == cmds.ErrRcvdError {
+			err = res.Error()
+		}
+		return v, err
+	}
+
+	slowErr := make(chan error)
+	go func() {
+		_, err := send([]string{"slow"})
+		slowErr <- err
+	}()
+
+	id := -1
+	for deadline := time.Now().Add(time.Second); id < 0 && time.Now().Before(deadline); {
+		v, err := send([]string{"diag", "cmds"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rle := range *v.(*[]*cmds.ReqLogEntry) {
+			if rle.Command == "slow" && rle.Active {
+				id = rle.ID
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if id < 0 {
+		t.Fatal("slow request not listed")
+	}
+
+	if _, err := send([]string{"diag", "cmds", "cancel"}, strconv.Itoa(id)); err != io.EOF {
+		t.Fatalf("expected cancel to succeed, got %v", err)
+	}
+
+	select {
+	case err := <-slowErr:
+		if err == nil || !strings.Contains(err.Error(), "context canceled") {
+			t.Errorf("expected the request to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request was not cancelled")
+	}
+
+	if _, err := send([]string{"diag", "cmds", "cancel"}, "1000"); err == nil {
+		t.Error("expected error cancelling unknown request")
+	}
+}
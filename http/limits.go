@@ -0,0 +1,177 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+const retryAfterHeader = "Retry-After"
+
+// ConcurrencyLimit restricts how many requests are run at the same time.
+// Requests beyond MaxRunning wait in a queue for a free slot.
+type ConcurrencyLimit struct {
+	// MaxRunning is the number of requests that may run at the same time.
+	// Zero means no limit.
+	MaxRunning int
+
+	// MaxQueued is the number of requests that may wait for a free slot.
+	// Requests arriving when the queue is full are rejected with 429 Too
+	// Many Requests.
+	MaxQueued int
+
+	// MaxWait is how long a request waits in the queue before it is rejected
+	// with 503 Service Unavailable. Zero means until the request is
+	// cancelled.
+	MaxWait time.Duration
+
+	// RetryAfter is sent in the Retry-After header of rejected requests. It
+	// defaults to MaxWait, and to one second if that is zero, too.
+	RetryAfter time.Duration
+}
+
+// rejection is the response to a request that was not admitted.
+type rejection struct {
+	status     int
+	retryAfter time.Duration
+	msg        string
+}
+
+// code is the error type a rejection is recorded with.
+func (r *rejection) code() cmdkit.ErrorType {
+	if r.status == http.StatusTooManyRequests {
+		return cmdkit.ErrClient
+	}
+	return cmdkit.ErrNormal
+}
+
+func (r *rejection) write(w http.ResponseWriter) {
+	w.Header().Set(retryAfterHeader, strconv.Itoa(ceilSeconds(r.retryAfter)))
+	w.Header().Set(contentTypeHeader, plainText)
+	w.WriteHeader(r.status)
+	w.Write([]byte(r.msg))
+}
+
+// limiter admits requests according to a ConcurrencyLimit.
+type limiter struct {
+	limit ConcurrencyLimit
+	slots chan struct{}
+
+	lock    sync.Mutex
+	waiting int
+}
+
+func newLimiter(limit ConcurrencyLimit) *limiter {
+	if limit.MaxRunning <= 0 {
+		return nil
+	}
+	return &limiter{
+		limit: limit,
+		slots: make(chan struct{}, limit.MaxRunning),
+	}
+}
+
+// acquire waits for a free slot. It returns a function that frees the slot,
+// or the rejection if the request could not be admitted.
+func (l *limiter) acquire(ctx context.Context, name string) (func(), *rejection) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-l.slots }
+
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	l.lock.Lock()
+	if l.waiting >= l.limit.MaxQueued {
+		l.lock.Unlock()
+		return nil, l.reject(http.StatusTooManyRequests, fmt.Sprintf("too many requests for %s, try again later", name))
+	}
+	l.waiting++
+	l.lock.Unlock()
+
+	defer func() {
+		l.lock.Lock()
+		l.waiting--
+		l.lock.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if l.limit.MaxWait > 0 {
+		timer := time.NewTimer(l.limit.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	case <-timeout:
+	case <-ctx.Done():
+	}
+	return nil, l.reject(http.StatusServiceUnavailable, fmt.Sprintf("%s is busy, try again later", name))
+}
+
+func (l *limiter) reject(status int, msg string) *rejection {
+	retryAfter := l.limit.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = l.limit.MaxWait
+	}
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	return &rejection{status: status, retryAfter: retryAfter, msg: msg}
+}
+
+// admission holds the limiters of a handler.
+type admission struct {
+	global *limiter
+	paths  map[string]*limiter
+}
+
+func newAdmission(cfg *ServerConfig) *admission {
+	a := &admission{
+		global: newLimiter(cfg.ConcurrencyLimit),
+		paths:  make(map[string]*limiter),
+	}
+	for path, limit := range cfg.CommandLimits {
+		if l := newLimiter(limit); l != nil {
+			a.paths[path] = l
+		}
+	}
+	return a
+}
+
+// limited reports whether requests for path may have to wait.
+func (a *admission) limited(path string) bool {
+	return a.global != nil || a.paths[path] != nil
+}
+
+// admit waits until the request for the command at path may run, first
+// for the limit of the command, then for the global one.
+func (a *admission) admit(ctx context.Context, path string) (func(), *rejection) {
+	releaseCmd, rej := a.paths[path].acquire(ctx, "command "+path)
+	if rej != nil {
+		return nil, rej
+	}
+
+	releaseGlobal, rej := a.global.acquire(ctx, "the server")
+	if rej != nil {
+		releaseCmd()
+		return nil, rej
+	}
+
+	return func() {
+		releaseGlobal()
+		releaseCmd()
+	}, nil
+}
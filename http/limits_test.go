@@ -0,0 +1,160 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+type queueTestEnv struct {
+	testEnv
+	log *cmds.ReqLog
+}
+
+func (env queueTestEnv) LogQueuedRequest(req *cmds.Request) (func(), func()) {
+	rle := env.log.AddQueued(req)
+	req.Context = rle.Context()
+	return func() { env.log.Start(rle) }, func() { env.log.Finish(rle) }
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	started := make(chan struct{}, 3)
+	unblock := make(chan struct{})
+	run := func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+		started <- struct{}{}
+		<-unblock
+		cmds.EmitOnce(re, "ok")
+	}
+
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"slow": &cmds.Command{Run: run},
+			"other": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					cmds.EmitOnce(re, "ok")
+				},
+			},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.CommandLimits = map[string]ConcurrencyLimit{
+		"slow": {MaxRunning: 1, MaxQueued: 1, MaxWait: 200 * time.Millisecond, RetryAfter: 3 * time.Second},
+	}
+
+	env := queueTestEnv{testEnv{rootCtx: context.Background()}, &cmds.ReqLog{}}
+	srv := httptest.NewServer(NewHandler(env, root, cfg))
+	defer srv.Close()
+
+	post := func(path string) *http.Response {
+		res, err := http.Post(srv.URL+path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		return res
+	}
+
+	// the first request runs, the second waits, the third is rejected
+	first := make(chan *http.Response)
+	go func() {
+		res, err := http.Post(srv.URL+"/slow", "", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		first <- res
+	}()
+	<-started
+
+	second := make(chan *http.Response)
+	go func() {
+		res, err := http.Post(srv.URL+"/slow", "", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		second <- res
+	}()
+
+	var running, queued int
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		running, queued = 0, 0
+		for _, rle := range env.log.Report() {
+			if rle.Active && rle.Queued {
+				queued++
+			} else if rle.Active {
+				running++
+			}
+		}
+		if queued == 1 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if running != 1 || queued != 1 {
+		t.Fatalf("expected 1 running and 1 queued request, got %d and %d", running, queued)
+	}
+
+	res := post("/slow")
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status %d for full queue, got %d", http.StatusTooManyRequests, res.StatusCode)
+	}
+	if h := res.Header.Get(retryAfterHeader); h != "3" {
+		t.Errorf("expected Retry-After to be 3, got %q", h)
+	}
+
+	if res := post("/other"); res.StatusCode != http.StatusOK {
+		t.Errorf("expected unlimited command to run, got status %d", res.StatusCode)
+	}
+
+	if res := <-second; res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d after waiting too long, got %d", http.StatusServiceUnavailable, res.StatusCode)
+	}
+
+	close(unblock)
+	if res := <-first; res.StatusCode != http.StatusOK {
+		t.Errorf("expected first request to succeed, got status %d", res.StatusCode)
+	}
+
+	// the slot is free again
+	if res := post("/slow"); res.StatusCode != http.StatusOK {
+		t.Errorf("expected request after release to succeed, got status %d", res.StatusCode)
+	}
+
+	for _, rle := range env.log.Report() {
+		if rle.Active {
+			t.Errorf("request %d still active", rle.ID)
+		}
+	}
+}
+
+func TestGlobalConcurrencyLimit(t *testing.T) {
+	l := newLimiter(ConcurrencyLimit{MaxRunning: 2})
+
+	release1, rej := l.acquire(context.Background(), "test")
+	if rej != nil {
+		t.Fatal(rej.msg)
+	}
+	if _, rej := l.acquire(context.Background(), "test"); rej != nil {
+		t.Fatal(rej.msg)
+	}
+
+	// no queue
+	if _, rej := l.acquire(context.Background(), "test"); rej == nil || rej.status != http.StatusTooManyRequests {
+		t.Fatalf("expected rejection with status %d, got %v", http.StatusTooManyRequests, rej)
+	}
+
+	release1()
+	if _, rej := l.acquire(context.Background(), "test"); rej != nil {
+		t.Fatal(rej.msg)
+	}
+
+	if newLimiter(ConcurrencyLimit{}) != nil {
+		t.Error("expected no limiter without MaxRunning")
+	}
+}
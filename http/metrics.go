@@ -0,0 +1,22 @@
+package http
+
+import (
+	"net/http"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// metricsContentType is the content type of the Prometheus text format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// NewMetricsHandler returns a handler that serves m in the Prometheus text
+// format. Mount it next to the handler returned by NewHandler, e.g. at
+// /debug/metrics/prometheus.
+func NewMetricsHandler(m *cmds.Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentTypeHeader, metricsContentType)
+		if err := m.WriteText(w); err != nil {
+			log.Error("error writing metrics: ", err)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"echo": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					cmds.EmitOnce(re, "hello")
+				},
+			},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.Metrics = cmds.NewMetrics()
+	cfg.CommandRateLimits = map[string]RateLimit{"echo": {Rate: 0.001, Burst: 1}}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, cfg))
+	defer srv.Close()
+
+	for i := 0; i < 2; i++ {
+		res, err := http.Post(srv.URL+"/echo", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+	}
+
+	metrics := httptest.NewServer(NewMetricsHandler(cfg.Metrics))
+	defer metrics.Close()
+
+	res, err := http.Get(metrics.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get(contentTypeHeader); ct != metricsContentType {
+		t.Errorf("expected content type %q, got %q", metricsContentType, ct)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`cmds_requests_total{command="echo",code="ok"} 1`,
+		`cmds_requests_total{command="echo",code="client"} 1`,
+		`cmds_emitted_values_total{command="echo"} 1`,
+		`cmds_emitted_bytes_total{command="echo"} 5`,
+		`cmds_active_requests{command="echo"} 0`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("expected line %q in\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsMiddlewareAndHandler(t *testing.T) {
+	metrics := cmds.NewMetrics()
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"count": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					re.SetLength(1)
+					cmds.Warn(re, "careful")
+					cmds.SetProgress(re, cmds.Progress{Done: 1, Unit: cmds.ProgressItems})
+					re.Emit(1)
+				},
+				Type: 0,
+			},
+		},
+		Middleware: []cmds.Middleware{metrics.Middleware()},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.Metrics = metrics
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, cfg))
+	defer srv.Close()
+
+	req, err := cmds.NewRequest(context.Background(), []string{"count"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewClient(srv.URL).Send(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []cmds.Progress
+	res.(cmds.ProgressResponse).OnProgress(func(p cmds.Progress) {
+		progress = append(progress, p)
+	})
+	for {
+		if _, err := res.Next(); err != nil {
+			break
+		}
+	}
+
+	// the observed emitter passes everything on to the HTTP emitter
+	if ws, exp := res.(cmds.WarningResponse).Warnings(), []string{"careful"}; !reflect.DeepEqual(ws, exp) {
+		t.Errorf("expected warnings %v, got %v", exp, ws)
+	}
+	if exp := []cmds.Progress{{Done: 1, Total: 1, Unit: cmds.ProgressItems}}; !reflect.DeepEqual(progress, exp) {
+		t.Errorf("expected progress %v, got %v", exp, progress)
+	}
+
+	var buf bytes.Buffer
+	if err := metrics.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`cmds_requests_total{command="count",code="ok"} 1`,
+		`cmds_emitted_values_total{command="count"} 1`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("expected line %q in\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// OpenAPIInfo is the metadata of the API included in its OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+// OpenAPISpec returns an OpenAPI 3 document describing the commands of root as
+// served by a handler with the given APIPath. Every callable command is
+// described as a POST operation. The result can be encoded as JSON.
+func OpenAPISpec(root *cmds.Command, apiPath string, info OpenAPIInfo) (map[string]interface{}, error) {
+	paths := make(map[string]interface{})
+
+	for _, ci := range cmds.ExportCommands(root) {
+		cmd, err := root.Get(ci.Path)
+		if err != nil {
+			return nil, err
+		}
+		if cmd.Run == nil {
+			continue
+		}
+
+		op, err := openAPIOperation(root, ci, cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		paths["/"+strings.Join(ci.Path, "/")] = map[string]interface{}{"post": op}
+	}
+
+	infoObj := map[string]interface{}{
+		"title":   info.Title,
+		"version": info.Version,
+	}
+	if info.Description != "" {
+		infoObj["description"] = info.Description
+	}
+
+	spec := map[string]interface{}{
+		"openapi": "3.0.0",
+		"info":    infoObj,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"Message": map[string]interface{}{"type": "string"},
+						"Code":    map[string]interface{}{"type": "integer"},
+						"Type":    map[string]interface{}{"type": "string", "enum": []string{"error"}},
+					},
+				},
+			},
+		},
+	}
+	if apiPath != "" {
+		spec["servers"] = []interface{}{map[string]interface{}{"url": apiPath}}
+	}
+
+	return spec, nil
+}
+
+// rootOperationID is the operationId of the root command, which has no path
+// to derive one from.
+const rootOperationID = "root"
+
+func openAPIOperation(root *cmds.Command, ci *cmds.CommandInfo, cmd *cmds.Command) (map[string]interface{}, error) {
+	id := strings.Join(ci.Path, "/")
+	if id == "" {
+		id = rootOperationID
+	}
+
+	op := map[string]interface{}{
+		"operationId": id,
+		"summary":     cmd.Helptext.Tagline,
+	}
+	if len(ci.Path) > 0 {
+		op["tags"] = []string{ci.Path[0]}
+	}
+	if desc := strings.TrimSpace(cmd.Helptext.ShortDescription); desc != "" {
+		op["description"] = desc
+	}
+	if cmd.Deprecated != nil {
+		op["deprecated"] = true
+	}
+
+	params, err := openAPIOptionParams(root, ci.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		argDescs    []string
+		argRequired bool
+		fileDescs   []string
+		fileReq     bool
+	)
+	for _, arg := range cmd.Arguments {
+		desc := fmt.Sprintf("%s: %s", arg.Name, arg.Description)
+		switch {
+		case arg.Type == cmdkit.ArgFile:
+			fileDescs = append(fileDescs, desc)
+			fileReq = fileReq || arg.Required
+		case arg.SupportsStdin:
+			// stdin arguments can also be sent as a file in the body
+			argDescs = append(argDescs, desc)
+			fileDescs = append(fileDescs, desc)
+		default:
+			argDescs = append(argDescs, desc)
+			argRequired = argRequired || arg.Required
+		}
+	}
+
+	if len(argDescs) > 0 {
+		params = append(params, map[string]interface{}{
+			"name":        "arg",
+			"in":          "query",
+			"description": strings.Join(argDescs, "\n"),
+			"required":    argRequired,
+			"style":       "form",
+			"explode":     true,
+			"schema": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+		})
+	}
+	op["parameters"] = params
+
+	if len(fileDescs) > 0 {
+		op["requestBody"] = map[string]interface{}{
+			"description": strings.Join(fileDescs, "\n"),
+			"required":    fileReq,
+			"content": map[string]interface{}{
+				"multipart/form-data": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"file": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{"type": "string", "format": "binary"},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	op["responses"] = map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "The output of the command.",
+			"headers":     openAPIResponseHeaders(),
+			"content":     openAPIContent(ci),
+		},
+		"400": openAPIErrorResponse("The request was invalid."),
+		"500": openAPIErrorResponse("The command failed."),
+	}
+
+	return op, nil
+}
+
+// openAPIOptionParams returns the query parameters of the options available
+// at path, including those inherited from its parents.
+func openAPIOptionParams(root *cmds.Command, path []string) ([]interface{}, error) {
+	cmdsAtPath, err := root.Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	params := []interface{}{}
+	for _, c := range cmdsAtPath {
+		for _, opt := range c.Options {
+			param := map[string]interface{}{
+				"name":        opt.Names()[0],
+				"in":          "query",
+				"description": opt.Description(),
+				"required":    false,
+				"schema":      openAPIOptionSchema(opt),
+			}
+			if cmds.OptionDeprecation(opt) != nil {
+				param["deprecated"] = true
+			}
+			params = append(params, param)
+		}
+	}
+
+	return params, nil
+}
+
+func openAPIOptionSchema(opt cmdkit.Option) map[string]interface{} {
+	schema := make(map[string]interface{})
+
+	switch opt.Type() {
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		schema["type"] = "integer"
+	case reflect.Float64:
+		schema["type"] = "number"
+	default:
+		schema["type"] = "string"
+	}
+
+	if dflt := opt.Default(); dflt != nil {
+		schema["default"] = dflt
+	}
+	return schema
+}
+
+func openAPIResponseHeaders() map[string]interface{} {
+	header := func(desc string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": desc,
+			"schema":      map[string]interface{}{"type": "string"},
+		}
+	}
+
+	return map[string]interface{}{
+		streamHeader:        header("Set to 1 if the output is a raw byte stream."),
+		channelHeader:       header("Set to 1 if the output is a stream of encoded values."),
+		StreamErrHeader:     header("Sent as HTTP trailer with the error message if the command fails after the output started."),
+		StreamWarningHeader: header("Sent as HTTP trailer with a warning if it can't be sent between the values."),
+	}
+}
+
+// openAPIContent describes the output of the command in each of its encodings.
+func openAPIContent(ci *cmds.CommandInfo) map[string]interface{} {
+	content := make(map[string]interface{})
+	for _, enc := range ci.Encodings {
+		mime, ok := mimeTypes[enc]
+		if !ok {
+			mime = plainText
+		}
+
+		schema := map[string]interface{}{"type": "string"}
+		if enc == cmds.JSON && ci.Type != nil {
+			schema = ci.Type
+		}
+		if _, ok := content[mime]; !ok {
+			content[mime] = map[string]interface{}{"schema": schema}
+		}
+	}
+	return content
+}
+
+func openAPIErrorResponse(desc string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": desc,
+		"content": map[string]interface{}{
+			applicationJson: map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"},
+			},
+		},
+	}
+}
+
+// serveOpenAPI writes the OpenAPI document of the handler's command tree.
+func (h *handler) serveOpenAPI(w http.ResponseWriter) {
+	spec, err := OpenAPISpec(h.root, h.cfg.APIPath, h.cfg.OpenAPIInfo)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, applicationJson)
+	err = json.NewEncoder(w).Encode(spec)
+	if err != nil {
+		log.Error("error writing OpenAPI document: ", err)
+	}
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmdkit.Option{
+			cmds.OptionEncodingType,
+		},
+		Subcommands: map[string]*cmds.Command{
+			"add": &cmds.Command{
+				Arguments: []cmdkit.Argument{
+					cmdkit.FileArg("path", true, true, "The files to add."),
+				},
+				Options: []cmdkit.Option{
+					cmdkit.IntOption("chunks", "c", "Number of chunks.").WithDefault(1),
+				},
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {},
+			},
+			"group": &cmds.Command{
+				Subcommands: map[string]*cmds.Command{
+					"cat": &cmds.Command{
+						Arguments: []cmdkit.Argument{
+							cmdkit.StringArg("ref", true, true, "The refs."),
+						},
+						Run:  func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {},
+						Type: VersionOutput{},
+					},
+				},
+			},
+		},
+	}
+
+	spec, err := OpenAPISpec(root, "/api/v0", OpenAPIInfo{Title: "test", Version: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// round-trip through JSON to make sure the document is encodable
+	buf, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		OpenAPI string
+		Servers []struct{ URL string }
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				Name     string
+				In       string
+				Required bool
+				Schema   map[string]interface{}
+			}
+			RequestBody *struct {
+				Required bool
+				Content  map[string]interface{}
+			}
+			Responses map[string]struct {
+				Headers map[string]interface{}
+				Content map[string]struct {
+					Schema map[string]interface{}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.OpenAPI != "3.0.0" || len(doc.Servers) != 1 || doc.Servers[0].URL != "/api/v0" {
+		t.Errorf("unexpected document header: %s", buf)
+	}
+	if len(doc.Paths) != 2 {
+		t.Fatalf("expected paths /add and /group/cat, got %v", doc.Paths)
+	}
+
+	add := doc.Paths["/add"]["post"]
+	if add.RequestBody == nil || !add.RequestBody.Required || add.RequestBody.Content["multipart/form-data"] == nil {
+		t.Errorf("expected required multipart body for /add, got %+v", add.RequestBody)
+	}
+	if len(add.Parameters) != 2 || add.Parameters[0].Name != cmds.EncLong || add.Parameters[1].Name != "chunks" {
+		t.Fatalf("unexpected parameters for /add: %+v", add.Parameters)
+	}
+	if add.Parameters[1].Schema["type"] != "integer" || add.Parameters[1].Schema["default"] != 1.0 {
+		t.Errorf("unexpected schema for chunks: %v", add.Parameters[1].Schema)
+	}
+	if _, ok := add.Responses["200"].Headers[StreamErrHeader]; !ok {
+		t.Errorf("expected %s header in response", StreamErrHeader)
+	}
+
+	cat := doc.Paths["/group/cat"]["post"]
+	if cat.RequestBody != nil {
+		t.Errorf("expected no body for /group/cat, got %+v", cat.RequestBody)
+	}
+	last := cat.Parameters[len(cat.Parameters)-1]
+	if last.Name != "arg" || last.In != "query" || !last.Required || last.Schema["type"] != "array" {
+		t.Errorf("unexpected arg parameter: %+v", last)
+	}
+	content := cat.Responses["200"].Content
+	if content[applicationJson].Schema["type"] != "object" {
+		t.Errorf("expected object schema for JSON output, got %v", content[applicationJson].Schema)
+	}
+	if _, ok := content["application/xml"]; !ok {
+		t.Errorf("expected XML output, got %v", content)
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	env := testEnv{rootCtx: context.Background()}
+	cfg := originCfg(defaultOrigins)
+	cfg.APIPath = "/api/v0"
+	cfg.OpenAPIPath = "/openapi.json"
+
+	srv := httptest.NewServer(NewHandler(env, cmdRoot, cfg))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/api/v0/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	assertStatus(t, res.StatusCode, http.StatusOK)
+	var doc struct {
+		Paths map[string]interface{}
+	}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc.Paths["/version"]; !ok {
+		t.Errorf("expected /version in served document, got %v", doc.Paths)
+	}
+}
+
+func TestOpenAPISpecRoot(t *testing.T) {
+	root := &cmds.Command{
+		Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {},
+		Subcommands: map[string]*cmds.Command{
+			"sub": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {},
+			},
+		},
+	}
+
+	spec, err := OpenAPISpec(root, "", OpenAPIInfo{Title: "test", Version: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := spec["paths"].(map[string]interface{})
+	for path, exp := range map[string]string{"/": rootOperationID, "/sub": "sub"} {
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected path %s, got %v", path, paths)
+		}
+		if id := item["post"].(map[string]interface{})["operationId"]; id != exp {
+			t.Errorf("expected operationId %q for %s, got %q", exp, path, id)
+		}
+	}
+}
@@ -32,7 +32,7 @@ func parseRequest(ctx context.Context, r *http.Request, root *cmds.Command) (*cm
 		return nil, ErrNotFound
 	}
 
-	sub := cmd.Subcommands[pth[len(pth)-1]]
+	_, sub := cmd.Subcommand(pth[len(pth)-1])
 
 	if sub == nil {
 		if cmd.Run == nil {
@@ -134,6 +134,11 @@ func parseRequest(ctx context.Context, r *http.Request, root *cmds.Command) (*cm
 		return nil, fmt.Errorf("File argument '%s' is required", requiredFile)
 	}
 
+	// continue the trace of the client
+	if sc, err := cmds.ParseTraceparent(r.Header.Get(traceparentHeader)); err == nil {
+		ctx = cmds.ContextWithSpanContext(ctx, sc)
+	}
+
 	req, err := cmds.NewRequest(ctx, pth, opts, args, f, root)
 	if err != nil {
 		return nil, err
@@ -144,7 +149,9 @@ func parseRequest(ctx context.Context, r *http.Request, root *cmds.Command) (*cm
 		return nil, err
 	}
 
-	err = req.FillDefaults()
+	// the sources of the root command are read on the client side, not from
+	// the daemon's environment and config
+	err = req.FillDefaultsFrom()
 	return req, err
 }
 
@@ -218,6 +225,11 @@ func parseResponse(httpRes *http.Response, req *cmds.Request) (cmds.Response, er
 			}
 		}
 
+		if httpRes.StatusCode == http.StatusTooManyRequests {
+			httpRes.Body.Close()
+			return nil, rateLimitError(httpRes, e)
+		}
+
 		res.initErr = e
 	}
 
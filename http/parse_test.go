@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"reflect"
 	"testing"
 
@@ -18,6 +19,7 @@ func TestParse(t *testing.T) {
 			"block": &cmds.Command{
 				Subcommands: map[string]*cmds.Command{
 					"put": &cmds.Command{
+						Aliases: []string{"store"},
 						Run: func(req *cmds.Request, resp cmds.ResponseEmitter, env cmds.Environment) {
 							defer resp.Close()
 							resp.Emit("done")
@@ -42,6 +44,20 @@ func TestParse(t *testing.T) {
 		t.Errorf("incorrect path %v, expected %v", pth, []string{"block", "put"})
 	}
 
+	r, err = http.NewRequest("GET", "/block/store", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = parseRequest(nil, r, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pth = req.Path
+	if pth[0] != "block" || pth[1] != "put" || len(pth) != 2 {
+		t.Errorf("incorrect path %v, expected %v", pth, []string{"block", "put"})
+	}
+
 	r, err = http.NewRequest("GET", "/block/bla", nil)
 	if err != nil {
 		t.Fatal(err)
@@ -102,6 +118,36 @@ func (tc parseReqTestCase) test(t *testing.T) {
 	}
 }
 
+func TestParseOptionSources(t *testing.T) {
+	os.Setenv("CMDSHTTPTEST_COLOR", "always")
+	defer os.Unsetenv("CMDSHTTPTEST_COLOR")
+
+	root := &cmds.Command{
+		Options: []cmdkit.Option{
+			cmdkit.StringOption("color", "").WithDefault("auto"),
+		},
+		OptionSources: []cmds.OptionSource{cmds.NewEnvSource("CMDSHTTPTEST_")},
+		Run:           func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {},
+	}
+
+	r, err := http.NewRequest("POST", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := parseRequest(nil, r, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the environment of the daemon does not apply to remote requests
+	if v := req.Options["color"]; v != "auto" {
+		t.Errorf("expected the default value, got %v", v)
+	}
+	if src := req.OptionSource("color"); src != cmds.SourceDefault {
+		t.Errorf("expected the value to come from %q, got %q", cmds.SourceDefault, src)
+	}
+}
+
 func TestParseRequest(t *testing.T) {
 	tcs := []parseReqTestCase{
 		{
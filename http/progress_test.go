@@ -0,0 +1,154 @@
+package http
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestProgress(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"count": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					re.SetLength(2)
+					for i := 1; i <= 2; i++ {
+						if err := cmds.SetProgress(re, cmds.Progress{Done: uint64(i), Unit: cmds.ProgressItems}); err != nil {
+							t.Error(err)
+						}
+						re.Emit(i)
+					}
+				},
+				Type: 0,
+			},
+		},
+	}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	req, err := cmds.NewRequest(context.Background(), []string{"count"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewClient(srv.URL).Send(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []cmds.Progress
+	res.(cmds.ProgressResponse).OnProgress(func(p cmds.Progress) {
+		progress = append(progress, p)
+	})
+
+	var values []interface{}
+	for {
+		v, err := res.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, *v.(*int))
+	}
+
+	if exp := []interface{}{1, 2}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("expected values %v, got %v", exp, values)
+	}
+	exp := []cmds.Progress{
+		{Done: 1, Total: 2, Unit: cmds.ProgressItems},
+		{Done: 2, Total: 2, Unit: cmds.ProgressItems},
+	}
+	if !reflect.DeepEqual(progress, exp) {
+		t.Errorf("expected progress %v, got %v", exp, progress)
+	}
+
+	// the frames are marked as progress on the wire
+	httpRes, err := http.Post(srv.URL+"/count", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(httpRes.Body)
+	httpRes.Body.Close()
+	if frame := `{"Done":1,"Total":2,"Unit":"items","Type":"progress"}`; !strings.HasPrefix(string(body), frame+"\n") {
+		t.Errorf("expected body to start with %s, got %s", frame, body)
+	}
+}
+
+func TestProgressBeforeFirstValue(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"cat": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					cmds.SetProgress(re, cmds.Progress{Done: 0, Total: 5, Unit: cmds.ProgressBytes})
+					re.Emit(strings.NewReader("hello"))
+				},
+			},
+			"nums": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					cmds.SetProgress(re, cmds.Progress{Done: 0, Total: 1, Unit: cmds.ProgressItems})
+					re.Emit(&protoNum{7})
+					cmds.SetProgress(re, cmds.Progress{Done: 1, Total: 1, Unit: cmds.ProgressItems})
+				},
+				Type: &protoNum{},
+			},
+		},
+	}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	// progress does not commit the response before the reader is emitted
+	httpRes, err := http.Post(srv.URL+"/cat", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(httpRes.Body)
+	httpRes.Body.Close()
+	if httpRes.Header.Get(streamHeader) != "1" {
+		t.Errorf("expected the %s header, got %v", streamHeader, httpRes.Header)
+	}
+	if string(body) != "hello" {
+		t.Errorf("expected body %q, got %q", "hello", body)
+	}
+
+	// progress frames are sent with protobuf encoding too
+	req, err := cmds.NewRequest(context.Background(), []string{"nums"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewClient(srv.URL, ClientWithEncoding(cmds.Protobuf)).Send(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []cmds.Progress
+	res.(cmds.ProgressResponse).OnProgress(func(p cmds.Progress) {
+		progress = append(progress, p)
+	})
+
+	var nums []protoNum
+	if err := cmds.Collect(res, &nums); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []protoNum{{7}}; !reflect.DeepEqual(nums, exp) {
+		t.Errorf("expected %v, got %v", exp, nums)
+	}
+	exp := []cmds.Progress{
+		{Done: 0, Total: 1, Unit: cmds.ProgressItems},
+		{Done: 1, Total: 1, Unit: cmds.ProgressItems},
+	}
+	if !reflect.DeepEqual(progress, exp) {
+		t.Errorf("expected progress %v, got %v", exp, progress)
+	}
+}
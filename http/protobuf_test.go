@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// protoNum is the protobuf message { uint64 n = 1; }
+type protoNum struct {
+	N uint64
+}
+
+func (n *protoNum) Marshal() ([]byte, error) {
+	buf := make([]byte, 1+binary.MaxVarintLen64)
+	buf[0] = 1<<3 | 0
+	return buf[:1+binary.PutUvarint(buf[1:], n.N)], nil
+}
+
+func (n *protoNum) Unmarshal(data []byte) error {
+	if len(data) == 0 {
+		n.N = 0
+		return nil
+	}
+	if data[0] != 1<<3|0 {
+		return errors.New("unexpected field")
+	}
+	x, l := binary.Uvarint(data[1:])
+	if l <= 0 {
+		return errors.New("invalid varint")
+	}
+	n.N = x
+	return nil
+}
+
+func TestProtobuf(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"nums": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					if cmds.GetEncoding(req) != cmds.Protobuf {
+						t.Errorf("expected protobuf encoding, got %s", cmds.GetEncoding(req))
+					}
+					re.Emit(&protoNum{1})
+					re.Emit(&protoNum{1000})
+					re.SetError("boom", cmdkit.ErrNormal)
+				},
+				Type: &protoNum{},
+			},
+			"fail": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					re.SetError("not found", cmdkit.ErrClient)
+				},
+				Type: &protoNum{},
+			},
+			"value": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					if cmds.GetEncoding(req) != cmds.Protobuf {
+						t.Errorf("expected protobuf encoding, got %s", cmds.GetEncoding(req))
+					}
+					cmds.EmitOnce(re, &protoNum{7})
+				},
+				// only the pointer is a message
+				Type: protoNum{},
+			},
+			"json": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					if cmds.GetEncoding(req) != cmds.JSON {
+						t.Errorf("expected JSON encoding, got %s", cmds.GetEncoding(req))
+					}
+					cmds.EmitOnce(re, "hello")
+				},
+				Type: "",
+			},
+		},
+	}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, ClientWithEncoding(cmds.Protobuf))
+	send := func(path string) cmds.Response {
+		req, err := cmds.NewRequest(context.Background(), []string{path}, nil, nil, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := client.Send(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	var nums []protoNum
+	err := cmds.Collect(send("nums"), &nums)
+	if e, ok := err.(*cmdkit.Error); !ok || e.Message != "boom" {
+		t.Errorf("expected the command error, got %#v", err)
+	}
+	if exp := []protoNum{{1}, {1000}}; !reflect.DeepEqual(nums, exp) {
+		t.Errorf("expected %v, got %v", exp, nums)
+	}
+
+	var n protoNum
+	err = cmds.ReadOnce(send("fail"), &n)
+	if e, ok := err.(*cmdkit.Error); !ok || e.Message != "not found" || e.Code != cmdkit.ErrClient {
+		t.Errorf("expected the command error, got %#v", err)
+	}
+
+	if err := cmds.ReadOnce(send("value"), &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.N != 7 {
+		t.Errorf("expected 7, got %d", n.N)
+	}
+
+	// commands without a message type fall back to JSON
+	var s string
+	if err := cmds.ReadOnce(send("json"), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "hello" {
+		t.Errorf("expected %q, got %q", "hello", s)
+	}
+}
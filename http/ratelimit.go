@@ -0,0 +1,276 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+const (
+	rateLimitHeader          = "X-RateLimit-Limit"
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	rateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// RateLimit restricts how many requests a client may make, using a token
+// bucket per client identity that holds up to Burst requests and is refilled
+// at Rate requests per second.
+type RateLimit struct {
+	// Rate is the number of requests per second a client may make on
+	// average. Zero means no limit.
+	Rate float64
+
+	// Burst is the number of requests a client may make at once. It is at
+	// least one.
+	Burst int
+}
+
+// RemoteAddrIdentity identifies clients by the host of their remote address.
+// It is the default ServerConfig.ClientIdentity.
+func RemoteAddrIdentity(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateLimitStatus is the state of the rate limit of a client as sent by the
+// server in the X-RateLimit headers.
+type RateLimitStatus struct {
+	// Limit is the number of requests the client may make at once.
+	Limit int
+	// Remaining is the number of requests the client may still make now.
+	Remaining int
+	// Reset is the time until the client may make Limit requests again.
+	Reset time.Duration
+}
+
+// RateLimitError is returned by the client if the server rejected a request
+// with 429 Too Many Requests, e.g. because the client exceeded its rate limit.
+type RateLimitError struct {
+	Message string
+	RateLimitStatus
+
+	// RetryAfter is the time after which the request may be retried.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s (retry after %s)", e.Message, e.RetryAfter)
+}
+
+// bucket is the token bucket of a client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps the token buckets of all clients for a RateLimit.
+type rateLimiter struct {
+	limit RateLimit
+
+	lock    sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &rateLimiter{limit: limit, buckets: make(map[string]*bucket)}
+}
+
+// take takes a token from the bucket of id. It returns whether there was
+// one and the status of the bucket afterwards.
+func (l *rateLimiter) take(id string, now time.Time) (bool, RateLimitStatus) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.calls++
+	if l.calls%1000 == 0 {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[id] = b
+	}
+	l.refill(b, now)
+
+	taken := b.tokens >= 1
+	if taken {
+		b.tokens--
+	}
+	return taken, l.status(b)
+}
+
+// refund puts back a token taken from the bucket of id.
+func (l *rateLimiter) refund(id string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if b, ok := l.buckets[id]; ok && b.tokens+1 <= float64(l.limit.Burst) {
+		b.tokens++
+	}
+}
+
+func (l *rateLimiter) refill(b *bucket, now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * l.limit.Rate
+	if max := float64(l.limit.Burst); b.tokens > max {
+		b.tokens = max
+	}
+	b.last = now
+}
+
+func (l *rateLimiter) status(b *bucket) RateLimitStatus {
+	missing := float64(l.limit.Burst) - b.tokens
+	return RateLimitStatus{
+		Limit:     l.limit.Burst,
+		Remaining: int(b.tokens),
+		Reset:     time.Duration(missing / l.limit.Rate * float64(time.Second)),
+	}
+}
+
+// retryAfter returns the time until the next token is available.
+func (l *rateLimiter) retryAfter(st RateLimitStatus) time.Duration {
+	if st.Remaining > 0 {
+		return 0
+	}
+	perToken := time.Duration(float64(time.Second) / l.limit.Rate)
+	return st.Reset - time.Duration(st.Limit-1)*perToken
+}
+
+// sweep drops full buckets, they are recreated as needed.
+func (l *rateLimiter) sweep(now time.Time) {
+	for id, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+// rateLimits holds the rate limiters of a handler.
+type rateLimits struct {
+	identity func(*http.Request) string
+	global   *rateLimiter
+	paths    map[string]*rateLimiter
+}
+
+func newRateLimits(cfg *ServerConfig) *rateLimits {
+	rl := &rateLimits{
+		identity: cfg.ClientIdentity,
+		global:   newRateLimiter(cfg.RateLimit),
+		paths:    make(map[string]*rateLimiter),
+	}
+	if rl.identity == nil {
+		rl.identity = RemoteAddrIdentity
+	}
+	for path, limit := range cfg.CommandRateLimits {
+		if l := newRateLimiter(limit); l != nil {
+			rl.paths[path] = l
+		}
+	}
+	return rl
+}
+
+// allow takes a token for the client of r from the limiters of the command at
+// path and the global one. It sets the rate limit headers of the most
+// restrictive limiter and returns false if the request must be rejected.
+func (rl *rateLimits) allow(w http.ResponseWriter, r *http.Request, path string) bool {
+	cmdLimiter := rl.paths[path]
+	if cmdLimiter == nil && rl.global == nil {
+		return true
+	}
+
+	id := rl.identity(r)
+	now := time.Now()
+
+	var (
+		limiter *rateLimiter
+		status  RateLimitStatus
+		ok      = true
+	)
+	for _, l := range []*rateLimiter{cmdLimiter, rl.global} {
+		if l == nil {
+			continue
+		}
+
+		taken, st := l.take(id, now)
+		if limiter == nil || !taken || st.Remaining < status.Remaining {
+			limiter, status = l, st
+		}
+		if !taken {
+			ok = false
+			if l == rl.global && cmdLimiter != nil {
+				cmdLimiter.refund(id)
+			}
+			break
+		}
+	}
+
+	h := w.Header()
+	h.Set(rateLimitHeader, strconv.Itoa(status.Limit))
+	h.Set(rateLimitRemainingHeader, strconv.Itoa(status.Remaining))
+	h.Set(rateLimitResetHeader, strconv.Itoa(ceilSeconds(status.Reset)))
+	if ok {
+		return true
+	}
+
+	h.Set(retryAfterHeader, strconv.Itoa(ceilSeconds(limiter.retryAfter(status))))
+	h.Set(contentTypeHeader, applicationJson)
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(&cmdkit.Error{
+		Message: "rate limit exceeded",
+		Code:    cmdkit.ErrClient,
+	})
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// rateLimitStatus reads the rate limit headers of a response. It returns
+// false if there are none.
+func rateLimitStatus(h http.Header) (RateLimitStatus, bool) {
+	limit, err := strconv.Atoi(h.Get(rateLimitHeader))
+	if err != nil {
+		return RateLimitStatus{}, false
+	}
+
+	remaining, _ := strconv.Atoi(h.Get(rateLimitRemainingHeader))
+	reset, _ := strconv.Atoi(h.Get(rateLimitResetHeader))
+	return RateLimitStatus{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Duration(reset) * time.Second,
+	}, true
+}
+
+// rateLimitError returns the error for a response rejected with 429 Too Many
+// Requests.
+func rateLimitError(httpRes *http.Response, e *cmdkit.Error) *RateLimitError {
+	err := &RateLimitError{Message: e.Message}
+	err.RateLimitStatus, _ = rateLimitStatus(httpRes.Header)
+
+	retryAfter := httpRes.Header.Get(retryAfterHeader)
+	if secs, perr := strconv.Atoi(retryAfter); perr == nil {
+		err.RetryAfter = time.Duration(secs) * time.Second
+	} else if t, perr := http.ParseTime(retryAfter); perr == nil {
+		err.RetryAfter = time.Until(t)
+	}
+	return err
+}
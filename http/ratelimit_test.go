@@ -0,0 +1,155 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestRateLimit(t *testing.T) {
+	run := func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+		cmds.EmitOnce(re, "ok")
+	}
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"cheap":     &cmds.Command{Run: run},
+			"expensive": &cmds.Command{Run: run},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.RateLimit = RateLimit{Rate: 0.1, Burst: 3}
+	cfg.CommandRateLimits = map[string]RateLimit{
+		"expensive": {Rate: 0.5, Burst: 1},
+	}
+	cfg.ClientIdentity = func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, cfg))
+	defer srv.Close()
+
+	post := func(path, user string) *http.Response {
+		httpReq, err := http.NewRequest("POST", srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpReq.Header.Set("X-User", user)
+		res, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := post("/expensive", "alice")
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got status %d", res.StatusCode)
+	}
+	if h := res.Header.Get(rateLimitRemainingHeader); h != "0" {
+		t.Errorf("expected no remaining requests for the command, got %q", h)
+	}
+
+	res = post("/expensive", "alice")
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, res.StatusCode)
+	}
+	if h := res.Header.Get(retryAfterHeader); h != "2" {
+		t.Errorf("expected Retry-After to be 2, got %q", h)
+	}
+	var e cmdkit.Error
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if e.Code != cmdkit.ErrClient {
+		t.Errorf("expected client error, got %v", e)
+	}
+
+	// the rejected request did not use up the global limit
+	for i := 0; i < 2; i++ {
+		res = post("/cheap", "alice")
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected request %d to succeed, got status %d", i, res.StatusCode)
+		}
+	}
+
+	// other clients have their own buckets
+	res = post("/expensive", "bob")
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected request of other client to succeed, got status %d", res.StatusCode)
+	}
+
+	// the client reports the rejection
+	c := NewClient(srv.URL).(*client)
+	c.httpClient = &http.Client{Transport: userTransport("bob")}
+	req, err := cmds.NewRequest(context.Background(), []string{"cheap"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := c.Send(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := r.(*Response).RateLimit(); st == nil || st.Limit != 3 || st.Remaining != 1 {
+		t.Errorf("expected rate limit status with 1 remaining request, got %+v", st)
+	}
+	if _, err := c.Send(req); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Send(req)
+	rlErr, ok := err.(*RateLimitError)
+	if !ok {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if rlErr.RetryAfter != 10*time.Second || rlErr.Limit != 3 || rlErr.Remaining != 0 {
+		t.Errorf("unexpected rate limit error %+v", rlErr)
+	}
+}
+
+type userTransport string
+
+func (u userTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.Header.Set("X-User", string(u))
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestTokenBucket(t *testing.T) {
+	l := newRateLimiter(RateLimit{Rate: 2, Burst: 2})
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.take("a", now); !ok {
+			t.Fatalf("expected token %d", i)
+		}
+	}
+
+	ok, st := l.take("a", now)
+	if ok {
+		t.Fatal("expected empty bucket")
+	}
+	if st.Reset != time.Second || l.retryAfter(st) != 500*time.Millisecond {
+		t.Errorf("expected reset in 1s and retry in 500ms, got %s and %s", st.Reset, l.retryAfter(st))
+	}
+
+	if ok, _ := l.take("a", now.Add(500*time.Millisecond)); !ok {
+		t.Error("expected refilled token")
+	}
+
+	l.sweep(now.Add(time.Hour))
+	if len(l.buckets) != 0 {
+		t.Errorf("expected full buckets to be dropped, got %d", len(l.buckets))
+	}
+}
@@ -13,9 +13,11 @@ import (
 
 var (
 	MIMEEncodings = map[string]cmds.EncodingType{
-		"application/json": cmds.JSON,
-		"application/xml":  cmds.XML,
-		"text/plain":       cmds.Text,
+		"application/json":     cmds.JSON,
+		"application/xml":      cmds.XML,
+		"application/protobuf": cmds.Protobuf,
+		"application/cbor":     cmds.CBOR,
+		"text/plain":           cmds.Text,
 	}
 )
 
@@ -30,6 +32,47 @@ type Response struct {
 	dec cmds.Decoder
 
 	initErr *cmdkit.Error
+
+	onProgress func(cmds.Progress)
+
+	// warnings are the warnings received so far, the first nTrailer of the
+	// trailer among them
+	warnings []string
+	nTrailer int
+}
+
+// OnProgress sets the function that is called with the progress reported by
+// the server. It is called while reading the values with Next or RawNext.
+func (res *Response) OnProgress(f func(cmds.Progress)) {
+	res.onProgress = f
+}
+
+// Warnings returns the warnings received so far. Those sent in the trailer
+// are only known once the body is read completely.
+func (res *Response) Warnings() []string {
+	ws := append([]string(nil), res.warnings...)
+	if res.res != nil {
+		ws = append(ws, res.res.Trailer[StreamWarningHeader][res.nTrailer:]...)
+	}
+	return ws
+}
+
+// trailerWarning returns the next warning of the trailer that RawNext didn't
+// return yet.
+func (res *Response) trailerWarning() (cmds.Warning, bool) {
+	if res.res == nil {
+		return cmds.Warning{}, false
+	}
+
+	ws := res.res.Trailer[StreamWarningHeader]
+	if res.nTrailer >= len(ws) {
+		return cmds.Warning{}, false
+	}
+
+	w := ws[res.nTrailer]
+	res.nTrailer++
+	res.warnings = append(res.warnings, w)
+	return cmds.Warning{Message: w}, true
 }
 
 func (res *Response) Request() *cmds.Request {
@@ -46,6 +89,20 @@ func (res *Response) Length() uint64 {
 	return res.length
 }
 
+// RateLimit returns the state of the rate limit of the client as reported by
+// the server, or nil if the server did not report it.
+func (res *Response) RateLimit() *RateLimitStatus {
+	if res.res == nil {
+		return nil
+	}
+
+	st, ok := rateLimitStatus(res.res.Header)
+	if !ok {
+		return nil
+	}
+	return &st
+}
+
 func (res *Response) RawNext() (interface{}, error) {
 	if res.initErr != nil {
 		err := res.initErr
@@ -58,6 +115,10 @@ func (res *Response) RawNext() (interface{}, error) {
 	// but only do that once
 	if res.dec == nil {
 		if res.rr == nil {
+			// the stream was read, now the trailer is there
+			if w, ok := res.trailerWarning(); ok {
+				return w, nil
+			}
 			return nil, io.EOF
 		}
 		rr := res.rr
@@ -80,6 +141,23 @@ func (res *Response) RawNext() (interface{}, error) {
 		err = io.EOF
 	}
 
+	if p, ok := m.Get().(cmds.Progress); ok && err == nil {
+		if res.onProgress != nil {
+			res.onProgress(p)
+		}
+		return res.RawNext()
+	}
+
+	if err == io.EOF {
+		if w, ok := res.trailerWarning(); ok {
+			return w, nil
+		}
+	}
+
+	if w, ok := m.Get().(cmds.Warning); ok && err == nil {
+		res.warnings = append(res.warnings, w.Message)
+	}
+
 	return m.Get(), err
 }
 
@@ -97,6 +175,8 @@ func (res *Response) Next() (interface{}, error) {
 	case *cmdkit.Error:
 		res.err = val
 		return nil, cmds.ErrRcvdError
+	case cmds.Warning:
+		return res.Next()
 	case cmds.Single:
 		return val.Value, nil
 	default:
@@ -15,11 +15,12 @@ import (
 var (
 	HeadRequest = fmt.Errorf("HEAD request")
 
-	AllowedExposedHeadersArr = []string{streamHeader, channelHeader, extraContentLengthHeader}
+	AllowedExposedHeadersArr = []string{streamHeader, channelHeader, extraContentLengthHeader, deprecationHeader, warningHeader}
 	AllowedExposedHeaders    = strings.Join(AllowedExposedHeadersArr, ", ")
 
 	mimeTypes = map[cmds.EncodingType]string{
 		cmds.Protobuf: "application/protobuf",
+		cmds.CBOR:     "application/cbor",
 		cmds.JSON:     "application/json",
 		cmds.XML:      "application/xml",
 		cmds.Text:     "text/plain",
@@ -64,6 +65,17 @@ type responseEmitter struct {
 	streaming bool
 	once      sync.Once
 	method    string
+
+	// wLock guards the writes to w and the fields below, Warn and
+	// SetProgress may be called while another goroutine emits values.
+	// started is set by the preamble, warnings and progress hold the
+	// warnings and the last progress set before, and inband is set if they
+	// can be sent between the values
+	wLock    sync.Mutex
+	started  bool
+	warnings []string
+	progress *cmds.Progress
+	inband   bool
 }
 
 func (re *responseEmitter) Emit(value interface{}) error {
@@ -82,6 +94,17 @@ func (re *responseEmitter) Emit(value interface{}) error {
 		return nil
 	}
 
+	if w, ok := value.(cmds.Warning); ok {
+		return re.Warn(w.Message)
+	}
+
+	if _, ok := value.(cmds.Single); ok {
+		defer re.Close()
+	}
+
+	re.wLock.Lock()
+	defer re.wLock.Unlock()
+
 	var err error
 
 	re.once.Do(func() { re.preamble(value) })
@@ -93,7 +116,6 @@ func (re *responseEmitter) Emit(value interface{}) error {
 
 	if single, ok := value.(cmds.Single); ok {
 		value = single.Value
-		defer re.Close()
 	}
 
 	if re.w == nil {
@@ -135,7 +157,76 @@ func (re *responseEmitter) Emit(value interface{}) error {
 	return err
 }
 
+// SetProgress sends p as a frame between the values. It is only sent with
+// frame encodings, see cmds.IsFrameEncoding, and not if the output is streamed
+// from an io.Reader, which progress can't be mixed with. Progress reported
+// before the first value is held back until the preamble, only the last is
+// sent.
+func (re *responseEmitter) SetProgress(p cmds.Progress) error {
+	re.wLock.Lock()
+	defer re.wLock.Unlock()
+
+	if p.Total == 0 {
+		p.Total = re.length
+	}
+
+	if !re.started {
+		re.progress = &p
+		return nil
+	}
+
+	return re.writeFrame(p)
+}
+
+// Warn sends a warning to the client. With frame encodings it is sent as a
+// frame between the values, like progress. Otherwise, and if the output is
+// streamed from an io.Reader or the request failed, it is sent in the
+// StreamWarningHeader trailer. Warnings set before the first value are held
+// back until the preamble decides which of these applies.
+func (re *responseEmitter) Warn(msg string) error {
+	re.wLock.Lock()
+	defer re.wLock.Unlock()
+
+	if !re.started {
+		re.warnings = append(re.warnings, msg)
+		return nil
+	}
+
+	return re.writeWarning(msg)
+}
+
+func (re *responseEmitter) writeWarning(msg string) error {
+	if re.method == "HEAD" || re.w == nil {
+		return nil
+	}
+
+	if !re.inband {
+		re.w.Header().Add(StreamWarningHeader, msg)
+		return nil
+	}
+
+	return re.writeFrame(cmds.Warning{Message: msg})
+}
+
+// writeFrame sends progress or a warning between the values. It is dropped
+// if that is not possible.
+func (re *responseEmitter) writeFrame(v interface{}) error {
+	if re.method == "HEAD" || re.w == nil || !re.inband {
+		return nil
+	}
+
+	// not re.enc, the command's encoder may only handle its own values
+	err := cmds.Encoders[re.encType](re.req)(re.w).Encode(v)
+	if f, ok := re.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return err
+}
+
 func (re *responseEmitter) SetLength(l uint64) {
+	re.wLock.Lock()
+	defer re.wLock.Unlock()
+
 	h := re.w.Header()
 	h.Set("X-Content-Length", strconv.FormatUint(l, 10))
 
@@ -143,6 +234,9 @@ func (re *responseEmitter) SetLength(l uint64) {
 }
 
 func (re *responseEmitter) Close() error {
+	re.wLock.Lock()
+	defer re.wLock.Unlock()
+
 	re.once.Do(func() { re.preamble(nil) })
 	return nil
 }
@@ -157,6 +251,9 @@ func (re *responseEmitter) SetError(v interface{}, errType cmdkit.ErrorType) {
 
 // Flush the http connection
 func (re *responseEmitter) Flush() {
+	re.wLock.Lock()
+	defer re.wLock.Unlock()
+
 	re.once.Do(func() { re.preamble(nil) })
 
 	if flusher, ok := re.w.(http.Flusher); ok {
@@ -211,8 +308,16 @@ func (re *responseEmitter) preamble(value interface{}) {
 		h.Set(channelHeader, "1")
 	}
 
-	// Set up our potential trailer
-	h.Set("Trailer", StreamErrHeader)
+	// Set up our potential trailers
+	h.Set("Trailer", StreamErrHeader+", "+StreamWarningHeader)
+
+	// warn about deprecated commands and options
+	if deprecated(re.req) {
+		h.Set(deprecationHeader, "true")
+	}
+	for _, w := range re.req.Deprecations() {
+		h.Add(warningHeader, fmt.Sprintf("299 - %q", w))
+	}
 
 	if mime == "" {
 		var ok bool
@@ -233,6 +338,41 @@ func (re *responseEmitter) preamble(value interface{}) {
 	h.Set("Access-Control-Expose-Headers", AllowedExposedHeaders)
 
 	re.w.WriteHeader(status)
+
+	re.started = true
+	re.inband = status == http.StatusOK && !re.streaming && cmds.IsFrameEncoding(re.encType)
+	if re.progress != nil {
+		if err := re.writeFrame(*re.progress); err != nil {
+			log.Error(err)
+		}
+		re.progress = nil
+	}
+	for _, msg := range re.warnings {
+		if err := re.writeWarning(msg); err != nil {
+			log.Error(err)
+		}
+	}
+	re.warnings = nil
+}
+
+// deprecated reports whether the command of req or one of its parents is
+// deprecated.
+func deprecated(req *cmds.Request) bool {
+	path := []*cmds.Command{req.Command}
+	if req.Root != nil {
+		// skip the root, it has no name to be deprecated under
+		path, _ = req.Root.Resolve(req.Path)
+		if len(path) > 0 {
+			path = path[1:]
+		}
+	}
+
+	for _, cmd := range path {
+		if cmd != nil && cmd.Deprecated != nil {
+			return true
+		}
+	}
+	return false
 }
 
 type responseWriterer interface {
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestTracing(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"echo": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					cmds.EmitOnce(re, "hello")
+				},
+			},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	serverSpans := &cmds.MemorySpanExporter{}
+	cfg.SpanExporter = serverSpans
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, cfg))
+	defer srv.Close()
+
+	clientSpans := &cmds.MemorySpanExporter{}
+	ctx := cmds.ContextWithSpanExporter(context.Background(), clientSpans)
+	req, err := cmds.NewRequest(ctx, []string{"echo"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	re, res := cmds.NewChanResponsePair(req)
+	go func() {
+		if err := NewClient(srv.URL).(cmds.Executor).Execute(req, re, env); err != nil {
+			t.Error(err)
+		}
+	}()
+	for {
+		if _, err := res.Next(); err != nil {
+			break
+		}
+	}
+
+	spans := make(map[string]*cmds.Span)
+	for _, s := range append(clientSpans.Spans(), serverSpans.Spans()...) {
+		spans[s.Name] = s
+	}
+
+	execute, send, handler, run := spans["cmds.execute"], spans["http.client"], spans["http.handler"], spans["cmds.run"]
+	if execute == nil || send == nil || handler == nil || run == nil {
+		t.Fatalf("missing spans, got %v", spans)
+	}
+	if send.ParentID != execute.SpanID || handler.ParentID != send.SpanID || run.ParentID != handler.SpanID {
+		t.Error("expected spans execute > http.client > http.handler > cmds.run")
+	}
+	for _, s := range []*cmds.Span{send, handler, run} {
+		if s.TraceID != execute.TraceID {
+			t.Errorf("span %s is in trace %s, expected %s", s.Name, s.TraceID, execute.TraceID)
+		}
+	}
+	if !handler.Sampled {
+		t.Error("expected the sampled flag to be propagated")
+	}
+
+	// the trace ID is echoed, also without a traceparent
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	for header, exp := range map[string]string{traceparent: "4bf92f3577b34da6a3ce929d0e0e4736", "": ""} {
+		httpReq, err := http.NewRequest("POST", srv.URL+"/echo", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header != "" {
+			httpReq.Header.Set(traceparentHeader, header)
+		}
+
+		httpRes, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpRes.Body.Close()
+
+		id := httpRes.Header.Get(traceIDHeader)
+		if len(id) != 32 || (exp != "" && id != exp) {
+			t.Errorf("traceparent %q: unexpected trace ID header %q", header, id)
+		}
+	}
+}
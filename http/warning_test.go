@@ -0,0 +1,149 @@
+package http
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestWarnings(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"values": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					cmds.Warn(re, "before")
+					re.Emit(1)
+					cmds.Warn(re, "between")
+					re.Emit(2)
+				},
+				Type: 0,
+			},
+			"stream": &cmds.Command{
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+					cmds.Warn(re, "before")
+					re.Emit(strings.NewReader("hello"))
+					cmds.Warn(re, "after")
+				},
+			},
+		},
+	}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	send := func(path string) cmds.Response {
+		req, err := cmds.NewRequest(context.Background(), []string{path}, nil, nil, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := NewClient(srv.URL).Send(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := send("values")
+	var values []interface{}
+	for {
+		v, err := res.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, *v.(*int))
+	}
+	if exp := []interface{}{1, 2}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("expected values %v, got %v", exp, values)
+	}
+	if ws, exp := res.(cmds.WarningResponse).Warnings(), []string{"before", "between"}; !reflect.DeepEqual(ws, exp) {
+		t.Errorf("expected warnings %v, got %v", exp, ws)
+	}
+
+	// raw output can't be mixed with warnings, they are sent in the trailer
+	res = send("stream")
+	v, err := res.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(v.(io.Reader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" {
+		t.Errorf("expected body %q, got %q", "hello", body)
+	}
+	if _, err := res.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	if ws, exp := res.(cmds.WarningResponse).Warnings(), []string{"before", "after"}; !reflect.DeepEqual(ws, exp) {
+		t.Errorf("expected warnings %v, got %v", exp, ws)
+	}
+
+	httpRes, err := http.Post(srv.URL+"/stream", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(httpRes.Body)
+	httpRes.Body.Close()
+	if ws, exp := httpRes.Trailer[StreamWarningHeader], []string{"before", "after"}; !reflect.DeepEqual(ws, exp) {
+		t.Errorf("expected trailer %v, got %v", exp, ws)
+	}
+}
+
+func TestWarnConcurrent(t *testing.T) {
+	root := &cmds.Command{
+		Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					cmds.Warn(re, "careful")
+				}
+			}()
+			for i := 0; i < 50; i++ {
+				re.Emit(i)
+			}
+			wg.Wait()
+		},
+		Type: 0,
+	}
+
+	env := testEnv{rootCtx: context.Background()}
+	srv := httptest.NewServer(NewHandler(env, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	req, err := cmds.NewRequest(context.Background(), nil, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewClient(srv.URL).Send(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []int
+	if err := cmds.Collect(res, &values); err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 50 {
+		t.Errorf("expected 50 values, got %d", len(values))
+	}
+
+	// no warning is lost around the preamble
+	if n := len(res.(cmds.WarningResponse).Warnings()); n != 50 {
+		t.Errorf("expected 50 warnings, got %d", n)
+	}
+}
@@ -0,0 +1,178 @@
+package cmds
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// NextValue reads the next value of res into the value pointed to by dst. It
+// returns io.EOF after the last value. If the command emitted an error, it is
+// returned as a *cmdkit.Error.
+//
+// Values are stored as they are if dst can hold them, after dereferencing
+// pointers like those returned for the Type of the command. Other values,
+// e.g. maps decoded from JSON without a Type, are converted by encoding them
+// to JSON and decoding that into dst.
+func NextValue(res Response, dst interface{}) error {
+	pv := reflect.ValueOf(dst)
+	if pv.Kind() != reflect.Ptr || pv.IsNil() {
+		return fmt.Errorf("NextValue needs a non-nil pointer, got %T", dst)
+	}
+
+	v, err := nextValue(res)
+	if err != nil {
+		return err
+	}
+
+	return assign(pv.Elem(), v)
+}
+
+// ForEach calls f with every value of res, until f returns an error. f must
+// be a func with a single parameter, which values are stored in like in
+// NextValue, and either no result or an error. It returns the error of f or
+// of the command, or nil after the last value.
+//
+//	err := cmds.ForEach(res, func(s *AddStatus) {
+//		fmt.Println(s.Current)
+//	})
+func ForEach(res Response, f interface{}) error {
+	fv := reflect.ValueOf(f)
+	if !fv.IsValid() || fv.Kind() != reflect.Func || fv.IsNil() {
+		return fmt.Errorf("ForEach needs a func(T) or func(T) error, got %T", f)
+	}
+
+	ft := fv.Type()
+	if ft.NumIn() != 1 || ft.IsVariadic() || ft.NumOut() > 1 || (ft.NumOut() == 1 && ft.Out(0) != errorType) {
+		return fmt.Errorf("ForEach needs a func(T) or func(T) error, got %T", f)
+	}
+
+	for {
+		v, err := nextValue(res)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		arg := reflect.New(ft.In(0)).Elem()
+		if err := assign(arg, v); err != nil {
+			return err
+		}
+
+		out := fv.Call([]reflect.Value{arg})
+		if len(out) == 1 && !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
+	}
+}
+
+// Collect appends all values of res to the slice pointed to by dst. The
+// values are stored like in NextValue. If the command fails, the values read
+// until then are kept and the error is returned.
+func Collect(res Response, dst interface{}) error {
+	pv := reflect.ValueOf(dst)
+	if pv.Kind() != reflect.Ptr || pv.IsNil() || pv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("Collect needs a pointer to a slice, got %T", dst)
+	}
+	slice := pv.Elem()
+
+	for {
+		v, err := nextValue(res)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		elem := reflect.New(slice.Type().Elem()).Elem()
+		if err := assign(elem, v); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+}
+
+// ReadOnce reads the only value of res into the value pointed to by dst, like
+// NextValue. It is meant for commands that emit their result with EmitOnce.
+// It returns io.ErrUnexpectedEOF if there is no value and an error if there
+// is more than one.
+func ReadOnce(res Response, dst interface{}) error {
+	err := NextValue(res, dst)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = nextValue(res)
+	switch err {
+	case io.EOF:
+		return nil
+	case nil:
+		return errors.New("command emitted more than one value")
+	default:
+		return err
+	}
+}
+
+// nextValue returns the next value of res, or the error of the command.
+func nextValue(res Response) (interface{}, error) {
+	v, err := res.Next()
+	if err != ErrRcvdError {
+		return v, err
+	}
+
+	// check for nil, a nil *cmdkit.Error is no nil error
+	if e := res.Error(); e != nil {
+		return nil, e
+	}
+	return nil, err
+}
+
+// assign stores v in dst, see NextValue.
+func assign(dst reflect.Value, v interface{}) error {
+	if v == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	dt := dst.Type()
+	switch {
+	case rv.Type().AssignableTo(dt):
+		dst.Set(rv)
+		return nil
+	case rv.Kind() == reflect.Ptr && rv.Type().Elem().AssignableTo(dt):
+		if rv.IsNil() {
+			dst.Set(reflect.Zero(dt))
+		} else {
+			dst.Set(rv.Elem())
+		}
+		return nil
+	case dt.Kind() == reflect.Ptr && rv.Type().AssignableTo(dt.Elem()):
+		p := reflect.New(dt.Elem())
+		p.Elem().Set(rv)
+		dst.Set(p)
+		return nil
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("can't store %T in %s: %s", v, dt, err)
+	}
+
+	p := reflect.New(dt)
+	if err := json.Unmarshal(buf, p.Interface()); err != nil {
+		return fmt.Errorf("can't store %T in %s: %s", v, dt, err)
+	}
+	dst.Set(p.Elem())
+	return nil
+}
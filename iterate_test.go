@@ -0,0 +1,163 @@
+package cmds
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+type iterValue struct {
+	N int
+}
+
+// emitPair returns a response with the values emitted by f.
+func emitPair(f func(re ResponseEmitter)) Response {
+	re, res := NewChanResponsePair(&Request{})
+	go func() {
+		defer re.Close()
+		f(re)
+	}()
+	return res
+}
+
+// jsonPair returns a response that decodes the values emitted by f from JSON.
+func jsonPair(typ interface{}, f func(re ResponseEmitter)) Response {
+	req := &Request{Command: &Command{Type: typ}}
+	r, w := io.Pipe()
+	re := NewWriterResponseEmitter(w, req, Encoders[JSON])
+	go func() {
+		defer re.Close()
+		f(re)
+	}()
+	return NewReaderResponse(r, JSON, req)
+}
+
+func TestNextValue(t *testing.T) {
+	res := emitPair(func(re ResponseEmitter) {
+		re.Emit(&iterValue{1})
+		re.Emit(iterValue{2})
+		re.SetError("boom", cmdkit.ErrClient)
+	})
+
+	var v iterValue
+	for _, exp := range []int{1, 2} {
+		if err := NextValue(res, &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.N != exp {
+			t.Errorf("expected %d, got %d", exp, v.N)
+		}
+	}
+
+	err := NextValue(res, &v)
+	if e, ok := err.(*cmdkit.Error); !ok || e.Message != "boom" || e.Code != cmdkit.ErrClient {
+		t.Errorf("expected the command error, got %#v", err)
+	}
+
+	if err := NextValue(res, v); err == nil {
+		t.Error("expected error for non-pointer destination")
+	}
+}
+
+func TestForEach(t *testing.T) {
+	// untyped JSON values are converted
+	res := jsonPair(nil, func(re ResponseEmitter) {
+		for i := 1; i <= 3; i++ {
+			re.Emit(iterValue{i})
+		}
+	})
+
+	var got []int
+	err := ForEach(res, func(v *iterValue) {
+		got = append(got, v.N)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []int{1, 2, 3}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+
+	// the error of f stops the iteration
+	res = emitPair(func(re ResponseEmitter) {
+		re.Emit(1)
+		re.Emit(2)
+	})
+	stop := errors.New("stop")
+	calls := 0
+	err = ForEach(res, func(n int) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("expected to stop after 1 call with %v, got %d calls and %v", stop, calls, err)
+	}
+
+	if err := ForEach(res, func() {}); err == nil {
+		t.Error("expected error for func without parameter")
+	}
+	var nilFunc func(int)
+	for _, f := range []interface{}{nil, nilFunc, func(...int) {}} {
+		if err := ForEach(res, f); err == nil {
+			t.Errorf("expected error for %#v", f)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	res := jsonPair(&iterValue{}, func(re ResponseEmitter) {
+		re.Emit(iterValue{1})
+		re.Emit(iterValue{2})
+	})
+
+	var vs []iterValue
+	if err := Collect(res, &vs); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []iterValue{{1}, {2}}; !reflect.DeepEqual(vs, exp) {
+		t.Errorf("expected %v, got %v", exp, vs)
+	}
+
+	// the values before the error are kept
+	res = emitPair(func(re ResponseEmitter) {
+		re.Emit("a")
+		re.SetError("boom", cmdkit.ErrNormal)
+	})
+	var ss []string
+	err := Collect(res, &ss)
+	if e, ok := err.(*cmdkit.Error); !ok || e.Message != "boom" {
+		t.Errorf("expected the command error, got %#v", err)
+	}
+	if exp := []string{"a"}; !reflect.DeepEqual(ss, exp) {
+		t.Errorf("expected %v, got %v", exp, ss)
+	}
+}
+
+func TestReadOnce(t *testing.T) {
+	var n int
+	res := emitPair(func(re ResponseEmitter) {
+		EmitOnce(re, 42)
+	})
+	if err := ReadOnce(res, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 {
+		t.Errorf("expected 42, got %d", n)
+	}
+
+	res = emitPair(func(re ResponseEmitter) {})
+	if err := ReadOnce(res, &n); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	res = emitPair(func(re ResponseEmitter) {
+		re.Emit(1)
+		re.Emit(2)
+	})
+	if err := ReadOnce(res, &n); err == nil {
+		t.Error("expected error for more than one value")
+	}
+}
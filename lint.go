@@ -0,0 +1,242 @@
+package cmds
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// Names of the checks done by Lint.
+const (
+	// LintInvalid findings are the errors reported by DebugValidate.
+	LintInvalid = "invalid"
+	// LintMissingTagline findings are callable commands without a tagline.
+	LintMissingTagline = "missing-tagline"
+	// LintTypeNotJSON findings are commands whose Type can't be decoded from
+	// its JSON encoding, e.g. by the HTTP client.
+	LintTypeNotJSON = "type-not-json"
+	// LintUnknownEncoding findings are Encoders keys that are not in the
+	// global Encoders map.
+	LintUnknownEncoding = "unknown-encoding"
+	// LintUnknownPostRun findings are PostRun keys that are not in
+	// PostRunTypes.
+	LintUnknownPostRun = "unknown-postrun"
+	// LintShadowedOption findings are options using a name of
+	// OptionEncodingType or OptionTimeout.
+	LintShadowedOption = "shadowed-option"
+	// LintUnreachable findings are subcommands that can't be called.
+	LintUnreachable = "unreachable"
+)
+
+// PostRunTypes lists the PostRunTypes of the response emitters in this
+// module. Lint reports PostRun entries of other types.
+var PostRunTypes = []PostRunType{CLI}
+
+// Finding is a problem in a command tree found by Lint.
+type Finding struct {
+	// Path is the path of the command the finding is about.
+	Path []string
+	// Check is the name of the check that failed, one of the Lint constants.
+	Check   string
+	Message string
+}
+
+func (f Finding) Error() string {
+	return fmt.Sprintf("/%s: %s (%s)", strings.Join(f.Path, "/"), f.Message, f.Check)
+}
+
+// Lint checks the command tree of root for mistakes beyond those reported by
+// DebugValidate, which are included as LintInvalid findings. The findings are
+// ordered by path.
+//
+// This operation is slow and should be called from tests only.
+func Lint(root *Command) []Finding {
+	var findings []Finding
+	add := func(path []string, check, format string, a ...interface{}) {
+		findings = append(findings, Finding{Path: path, Check: check, Message: fmt.Sprintf(format, a...)})
+	}
+
+	for path, errs := range root.DebugValidate() {
+		p := []string{}
+		if path != "" {
+			p = strings.Split(path[1:], "/")
+		}
+		for _, err := range errs {
+			add(p, LintInvalid, "%s", err)
+		}
+	}
+
+	var visit func(path []string, cm *Command)
+	visit = func(path []string, cm *Command) {
+		if cm.Run != nil && strings.TrimSpace(cm.Helptext.Tagline) == "" {
+			add(path, LintMissingTagline, "command has no tagline")
+		}
+
+		if cm.Type != nil {
+			if problem := jsonProblem(reflect.TypeOf(cm.Type), make(map[reflect.Type]bool)); problem != "" {
+				add(path, LintTypeNotJSON, "type %T does not round-trip through JSON: %s", cm.Type, problem)
+			}
+		}
+
+		for _, enc := range sortedEncodings(cm.Encoders) {
+			if _, ok := Encoders[enc]; !ok {
+				add(path, LintUnknownEncoding, "encoder for unknown encoding %q", enc)
+			}
+		}
+
+		for typ := range cm.PostRun {
+			known := false
+			for _, t := range PostRunTypes {
+				known = known || t == typ
+			}
+			if !known {
+				add(path, LintUnknownPostRun, "PostRun for unknown type %q", typ)
+			}
+		}
+
+		for _, opt := range cm.Options {
+			if global := shadowedOption(opt); global != "" {
+				add(path, LintShadowedOption, "option %s shadows the global %s option", opt.Name(), global)
+			}
+		}
+
+		names := make([]string, 0, len(cm.Subcommands))
+		for name := range cm.Subcommands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			sub := cm.Subcommands[name]
+			subPath := append(path[:len(path):len(path)], name)
+
+			switch {
+			case sub == nil:
+				add(subPath, LintUnreachable, "subcommand is nil")
+				continue
+			case cm.External:
+				add(subPath, LintUnreachable, "subcommand of external command")
+			case name == "" || strings.HasPrefix(name, "-") || strings.IndexFunc(name, isSpace) >= 0:
+				add(subPath, LintUnreachable, "subcommand name %q can't be typed on the command line", name)
+			}
+
+			visit(subPath, sub)
+		}
+	}
+	visit([]string{}, root)
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return strings.Join(findings[i].Path, "/") < strings.Join(findings[j].Path, "/")
+	})
+	return findings
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+func sortedEncodings(m EncoderMap) []EncodingType {
+	encs := make([]EncodingType, 0, len(m))
+	for enc := range m {
+		encs = append(encs, enc)
+	}
+	sort.Slice(encs, func(i, j int) bool { return encs[i] < encs[j] })
+	return encs
+}
+
+// shadowedOption returns the name of the global option opt shares a name
+// with, unless opt is that global option.
+func shadowedOption(opt cmdkit.Option) string {
+	if do, ok := opt.(*deprecatedOption); ok {
+		opt = do.Option
+	}
+
+	for _, global := range []cmdkit.Option{OptionEncodingType, OptionTimeout} {
+		if opt == global {
+			continue
+		}
+		for _, name := range opt.Names() {
+			for _, gname := range global.Names() {
+				if name == gname {
+					return global.Name()
+				}
+			}
+		}
+	}
+	return ""
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// jsonProblem explains why values of type t can't be decoded from their JSON
+// encoding, or returns the empty string if they can.
+func jsonProblem(t reflect.Type, visiting map[reflect.Type]bool) string {
+	implements := func(iface reflect.Type) bool {
+		return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+	}
+
+	switch {
+	case implements(jsonMarshalerType):
+		if !implements(jsonUnmarshalerType) {
+			return fmt.Sprintf("%s implements json.Marshaler but not json.Unmarshaler", t)
+		}
+		return ""
+	case implements(jsonUnmarshalerType):
+		return ""
+	case implements(textMarshalerType):
+		if !implements(textUnmarshalerType) {
+			return fmt.Sprintf("%s implements encoding.TextMarshaler but not encoding.TextUnmarshaler", t)
+		}
+		return ""
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return jsonProblem(t.Elem(), visiting)
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) {
+				return fmt.Sprintf("map key type %s is not supported", t.Key())
+			}
+		}
+		return jsonProblem(t.Elem(), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			return ""
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Tag.Get("json") == "-" || (f.PkgPath != "" && !f.Anonymous) {
+				continue
+			}
+			if problem := jsonProblem(f.Type, visiting); problem != "" {
+				return fmt.Sprintf("field %s: %s", f.Name, problem)
+			}
+		}
+		return ""
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			return fmt.Sprintf("interface type %s can't be decoded", t)
+		}
+		return ""
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return fmt.Sprintf("%s values can't be encoded", t.Kind())
+	default:
+		return ""
+	}
+}
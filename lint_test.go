@@ -0,0 +1,109 @@
+package cmds
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+type lintMarshalOnly struct{}
+
+func (lintMarshalOnly) MarshalJSON() ([]byte, error) { return []byte("{}"), nil }
+
+type lintBadOutput struct {
+	Name   string
+	Nested struct {
+		Done func()
+	}
+}
+
+func TestLint(t *testing.T) {
+	helptext := cmdkit.HelpText{Tagline: "Do things."}
+	root := &Command{
+		Options: []cmdkit.Option{
+			OptionEncodingType,
+			OptionTimeout,
+		},
+		Subcommands: map[string]*Command{
+			"ok": &Command{
+				Helptext: helptext,
+				Run:      noop,
+				Type:     &treeTestOutput{},
+				Encoders: EncoderMap{Text: Encoders[TextNewline]},
+				PostRun: PostRunMap{
+					CLI: func(req *Request, re ResponseEmitter) ResponseEmitter { return re },
+				},
+			},
+			"bad": &Command{
+				Run:  noop,
+				Type: lintBadOutput{},
+				Options: []cmdkit.Option{
+					cmdkit.StringOption("timeout", "t", "The timeout."),
+					cmdkit.StringOption("format", "f", "The format."),
+				},
+				Encoders: EncoderMap{"yaml": Encoders[JSON]},
+				PostRun: PostRunMap{
+					"gui": func(req *Request, re ResponseEmitter) ResponseEmitter { return re },
+				},
+				Subcommands: map[string]*Command{
+					"-x":  &Command{Helptext: helptext, Run: noop},
+					"nil": nil,
+				},
+			},
+			"marshal": &Command{
+				Helptext: helptext,
+				Run:      noop,
+				Type:     map[string]lintMarshalOnly{},
+			},
+			"ext": &Command{
+				External: true,
+				Subcommands: map[string]*Command{
+					"sub": &Command{},
+				},
+			},
+			"dep": &Command{
+				Deprecated: &Deprecation{},
+			},
+		},
+	}
+
+	type finding struct {
+		path, check string
+	}
+
+	var got []finding
+	for _, f := range Lint(root) {
+		got = append(got, finding{"/" + strings.Join(f.Path, "/"), f.Check})
+		if f.Message == "" {
+			t.Errorf("finding without message: %v", f)
+		}
+	}
+
+	exp := []finding{
+		{"/bad", LintInvalid},
+		{"/bad", LintMissingTagline},
+		{"/bad", LintTypeNotJSON},
+		{"/bad", LintUnknownEncoding},
+		{"/bad", LintUnknownPostRun},
+		{"/bad", LintShadowedOption},
+		{"/bad/-x", LintUnreachable},
+		{"/bad/nil", LintUnreachable},
+		{"/dep", LintInvalid},
+		{"/ext/sub", LintUnreachable},
+		{"/marshal", LintTypeNotJSON},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected findings\n%v\ngot\n%v", exp, got)
+	}
+
+	for _, f := range Lint(root) {
+		if f.Check == LintTypeNotJSON && f.Path[0] == "bad" {
+			const msg = "type cmds.lintBadOutput does not round-trip through JSON: field Nested: field Done: func values can't be encoded"
+			if f.Message != msg {
+				t.Errorf("expected message %q, got %q", msg, f.Message)
+			}
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package cmds
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// DefaultDurationBuckets are the upper bounds in seconds of the buckets of
+// the request duration histogram, unless set otherwise in NewMetrics.
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// errorCodes are the values of the code label of the request counter.
+var errorCodes = map[cmdkit.ErrorType]string{
+	cmdkit.ErrNormal:         "normal",
+	cmdkit.ErrClient:         "client",
+	cmdkit.ErrImplementation: "implementation",
+	cmdkit.ErrNotFound:       "not_found",
+	cmdkit.ErrFatal:          "fatal",
+}
+
+// Metrics records the number, duration, errors and output size of requests
+// per command path, as well as the number of requests currently running. Use
+// WriteText to export them in the Prometheus text format.
+type Metrics struct {
+	buckets []float64
+
+	lock     sync.Mutex
+	commands map[string]*commandMetrics
+}
+
+type commandMetrics struct {
+	// requests counts finished requests by error code, "ok" if there was
+	// none.
+	requests map[string]uint64
+	active   int64
+	values   uint64
+	bytes    uint64
+
+	// buckets counts the durations up to the corresponding upper bound.
+	// They are not cumulative, WriteText sums them up.
+	buckets  []uint64
+	duration float64
+}
+
+// NewMetrics returns empty Metrics. The request durations are counted in
+// buckets with the given upper bounds in seconds, DefaultDurationBuckets if
+// there are none.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultDurationBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		buckets:  buckets,
+		commands: make(map[string]*commandMetrics),
+	}
+}
+
+// command returns the metrics of the command at path. The lock must be held.
+func (m *Metrics) command(path string) *commandMetrics {
+	cm, ok := m.commands[path]
+	if !ok {
+		cm = &commandMetrics{
+			requests: make(map[string]uint64),
+			buckets:  make([]uint64, len(m.buckets)),
+		}
+		m.commands[path] = cm
+	}
+	return cm
+}
+
+// Observation records a single request in Metrics.
+type Observation struct {
+	m     *Metrics
+	path  string
+	start time.Time
+
+	lock sync.Mutex
+	code string
+	done bool
+}
+
+// Start starts recording req. Call Finish when it is done.
+func (m *Metrics) Start(req *Request) *Observation {
+	o := &Observation{
+		m:     m,
+		path:  strings.Join(req.Path, "/"),
+		start: time.Now(),
+		code:  "ok",
+	}
+
+	m.lock.Lock()
+	m.command(o.path).active++
+	m.lock.Unlock()
+
+	return o
+}
+
+type observationKey struct {
+	m *Metrics
+}
+
+// Context returns ctx marked as recorded by o. The Middleware of o's Metrics
+// doesn't record requests with such a context again.
+func (o *Observation) Context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, observationKey{o.m}, o)
+}
+
+// Emitter returns a ResponseEmitter that records the errors and values sent
+// on re. It forwards SetEncoder, SetProgress, Warn, Type, Head and Flush to
+// re, but hides any other methods of re.
+func (o *Observation) Emitter(re ResponseEmitter) ResponseEmitter {
+	return observeEmitter(re, o)
+}
+
+// SetError records that the request failed with an error of type code. If
+// there are several errors, the last one counts.
+func (o *Observation) SetError(code cmdkit.ErrorType) {
+	name, ok := errorCodes[code]
+	if !ok {
+		name = strconv.Itoa(int(code))
+	}
+
+	o.lock.Lock()
+	o.code = name
+	o.lock.Unlock()
+}
+
+// Finish records the request as done. Calls after the first are ignored.
+func (o *Observation) Finish() {
+	o.lock.Lock()
+	if o.done {
+		o.lock.Unlock()
+		return
+	}
+	o.done = true
+	code := o.code
+	o.lock.Unlock()
+
+	d := time.Since(o.start).Seconds()
+
+	o.m.lock.Lock()
+	defer o.m.lock.Unlock()
+
+	cm := o.m.command(o.path)
+	cm.active--
+	cm.requests[code]++
+	cm.duration += d
+	if i := sort.SearchFloat64s(o.m.buckets, d); i < len(cm.buckets) {
+		cm.buckets[i]++
+	}
+}
+
+func (o *Observation) observeError(err interface{}, code cmdkit.ErrorType) {
+	o.SetError(code)
+}
+
+func (o *Observation) observeValue() {
+	o.m.lock.Lock()
+	defer o.m.lock.Unlock()
+
+	o.m.command(o.path).values++
+}
+
+func (o *Observation) observeBytes(n int64) {
+	o.m.lock.Lock()
+	defer o.m.lock.Unlock()
+
+	o.m.command(o.path).bytes += uint64(n)
+}
+
+// Middleware returns a Middleware that records all requests in m, except for
+// those whose context is marked by an Observation of m, see
+// Observation.Context. Use it as the Middleware of the root command. Note that
+// commands then no longer see the other methods of the ResponseEmitter passed
+// to them, except for SetEncoder, SetProgress, Warn, Type, Head and Flush.
+func (m *Metrics) Middleware() Middleware {
+	return func(next Executor) Executor {
+		return ExecutorFunc(func(req *Request, re ResponseEmitter, env Environment) error {
+			if _, ok := req.Context.Value(observationKey{m}).(*Observation); ok {
+				return next.Execute(req, re, env)
+			}
+
+			o := m.Start(req)
+			defer o.Finish()
+
+			err := next.Execute(req, o.Emitter(re), env)
+			if err != nil {
+				o.SetError(cmdkit.ErrNormal)
+			}
+			return err
+		})
+	}
+}
+
+// WriteText writes the metrics to w in the Prometheus text exposition format.
+func (m *Metrics) WriteText(w io.Writer) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	paths := make([]string, 0, len(m.commands))
+	for path := range m.commands {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "# HELP cmds_requests_total Number of finished requests by command and error code.")
+	fmt.Fprintln(bw, "# TYPE cmds_requests_total counter")
+	for _, path := range paths {
+		requests := m.commands[path].requests
+		codes := make([]string, 0, len(requests))
+		for code := range requests {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+
+		for _, code := range codes {
+			fmt.Fprintf(bw, "cmds_requests_total{command=%s,code=%q} %d\n", label(path), code, requests[code])
+		}
+	}
+
+	fmt.Fprintln(bw, "# HELP cmds_request_duration_seconds Duration of finished requests by command.")
+	fmt.Fprintln(bw, "# TYPE cmds_request_duration_seconds histogram")
+	for _, path := range paths {
+		cm := m.commands[path]
+		var count uint64
+		for i, n := range cm.buckets {
+			count += n
+			fmt.Fprintf(bw, "cmds_request_duration_seconds_bucket{command=%s,le=%q} %d\n", label(path), formatFloat(m.buckets[i]), count)
+		}
+
+		var total uint64
+		for _, n := range cm.requests {
+			total += n
+		}
+		fmt.Fprintf(bw, "cmds_request_duration_seconds_bucket{command=%s,le=\"+Inf\"} %d\n", label(path), total)
+		fmt.Fprintf(bw, "cmds_request_duration_seconds_sum{command=%s} %s\n", label(path), formatFloat(cm.duration))
+		fmt.Fprintf(bw, "cmds_request_duration_seconds_count{command=%s} %d\n", label(path), total)
+	}
+
+	fmt.Fprintln(bw, "# HELP cmds_emitted_values_total Number of values emitted by command.")
+	fmt.Fprintln(bw, "# TYPE cmds_emitted_values_total counter")
+	for _, path := range paths {
+		fmt.Fprintf(bw, "cmds_emitted_values_total{command=%s} %d\n", label(path), m.commands[path].values)
+	}
+
+	fmt.Fprintln(bw, "# HELP cmds_emitted_bytes_total Size of the raw data emitted by command.")
+	fmt.Fprintln(bw, "# TYPE cmds_emitted_bytes_total counter")
+	for _, path := range paths {
+		fmt.Fprintf(bw, "cmds_emitted_bytes_total{command=%s} %d\n", label(path), m.commands[path].bytes)
+	}
+
+	fmt.Fprintln(bw, "# HELP cmds_active_requests Number of running requests by command.")
+	fmt.Fprintln(bw, "# TYPE cmds_active_requests gauge")
+	for _, path := range paths {
+		fmt.Fprintf(bw, "cmds_active_requests{command=%s} %d\n", label(path), m.commands[path].active)
+	}
+
+	return bw.Flush()
+}
+
+// label quotes a label value as required by the text format.
+func label(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `"`, `\"`, -1)
+	v = strings.Replace(v, "\n", `\n`, -1)
+	return `"` + v + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,88 @@
+package cmds
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics(0.5, 0.1)
+
+	root := &Command{
+		Middleware: []Middleware{m.Middleware()},
+		Subcommands: map[string]*Command{
+			"cat": &Command{
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					re.Emit("foo")
+					re.Emit([]byte("ba\"r"))
+				},
+			},
+			"fail": &Command{
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					re.SetError("not here", cmdkit.ErrNotFound)
+				},
+			},
+		},
+	}
+
+	run := func(path string) {
+		req, err := NewRequest(context.Background(), []string{path}, nil, nil, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		re, res := NewChanResponsePair(req)
+		go func() {
+			if err := NewExecutor(root).Execute(req, re, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+		for {
+			if _, err := res.Next(); err != nil {
+				break
+			}
+		}
+	}
+
+	run("cat")
+	run("cat")
+	run("fail")
+
+	// a running request
+	m.Start(&Request{Path: []string{"cat"}})
+
+	var buf bytes.Buffer
+	if err := m.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, line := range []string{
+		`cmds_requests_total{command="cat",code="ok"} 2`,
+		`cmds_requests_total{command="fail",code="not_found"} 1`,
+		`cmds_request_duration_seconds_bucket{command="cat",le="0.1"} 2`,
+		`cmds_request_duration_seconds_bucket{command="cat",le="0.5"} 2`,
+		`cmds_request_duration_seconds_bucket{command="cat",le="+Inf"} 2`,
+		`cmds_request_duration_seconds_count{command="cat"} 2`,
+		`cmds_emitted_values_total{command="cat"} 4`,
+		`cmds_emitted_bytes_total{command="cat"} 14`,
+		`cmds_emitted_bytes_total{command="fail"} 0`,
+		`cmds_active_requests{command="cat"} 1`,
+		`cmds_active_requests{command="fail"} 0`,
+		`# TYPE cmds_request_duration_seconds histogram`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected line %q in\n%s", line, out)
+		}
+	}
+}
+
+func TestMetricsLabel(t *testing.T) {
+	if l := label("a\"b\\c\nd"); l != `"a\"b\\c\nd"` {
+		t.Errorf("unexpected label %s", l)
+	}
+}
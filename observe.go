@@ -0,0 +1,122 @@
+package cmds
+
+import (
+	"io"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// emitObserver is notified of what is sent on a ResponseEmitter, see
+// observeEmitter.
+type emitObserver interface {
+	// observeError is called with errors set or emitted as cmdkit.Error.
+	observeError(err interface{}, code cmdkit.ErrorType)
+
+	// observeValue is called for every emitted value.
+	observeValue()
+
+	// observeBytes is called with the size of the raw data emitted, i.e. of
+	// []byte and string values and of what is read from io.Reader values.
+	observeBytes(n int64)
+}
+
+// observeEmitter returns a ResponseEmitter that notifies obs of everything
+// sent on re. Besides the methods of ResponseEmitter, e.g. SetLength, it
+// forwards SetEncoder, SetProgress, Warn, Type, Head and Flush to re, but
+// hides any other methods of re.
+func observeEmitter(re ResponseEmitter, obs emitObserver) ResponseEmitter {
+	return &observedEmitter{ResponseEmitter: re, obs: obs}
+}
+
+type observedEmitter struct {
+	ResponseEmitter
+	obs emitObserver
+}
+
+func (re *observedEmitter) SetError(err interface{}, code cmdkit.ErrorType) {
+	re.obs.observeError(err, code)
+	re.ResponseEmitter.SetError(err, code)
+}
+
+func (re *observedEmitter) Emit(v interface{}) error {
+	// warnings are not values
+	if _, ok := v.(Warning); ok {
+		return re.ResponseEmitter.Emit(v)
+	}
+
+	value, single := v.(Single)
+	if single {
+		v = value.Value
+	}
+
+	re.obs.observeValue()
+	switch val := v.(type) {
+	case []byte:
+		re.obs.observeBytes(int64(len(val)))
+	case string:
+		re.obs.observeBytes(int64(len(val)))
+	case io.Reader:
+		v = &observedReader{Reader: val, obs: re.obs}
+	case cmdkit.Error:
+		re.obs.observeError(val.Message, val.Code)
+	case *cmdkit.Error:
+		re.obs.observeError(val.Message, val.Code)
+	}
+
+	if single {
+		v = Single{v}
+	}
+	return re.ResponseEmitter.Emit(v)
+}
+
+func (re *observedEmitter) SetEncoder(enc func(io.Writer) Encoder) {
+	if ee, ok := re.ResponseEmitter.(EncodingEmitter); ok {
+		ee.SetEncoder(enc)
+	}
+}
+
+func (re *observedEmitter) SetProgress(p Progress) error {
+	return SetProgress(re.ResponseEmitter, p)
+}
+
+func (re *observedEmitter) Warn(msg string) error {
+	return Warn(re.ResponseEmitter, msg)
+}
+
+func (re *observedEmitter) Type() PostRunType {
+	if typer, ok := re.ResponseEmitter.(interface {
+		Type() PostRunType
+	}); ok {
+		return typer.Type()
+	}
+	return ""
+}
+
+func (re *observedEmitter) Head() Head {
+	if h, ok := re.ResponseEmitter.(Header); ok {
+		return h.Head()
+	}
+	return Head{}
+}
+
+func (re *observedEmitter) Flush() {
+	if f, ok := re.ResponseEmitter.(interface {
+		Flush()
+	}); ok {
+		f.Flush()
+	}
+}
+
+// observedReader counts the bytes read from an emitted reader.
+type observedReader struct {
+	io.Reader
+	obs emitObserver
+}
+
+func (r *observedReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.obs.observeBytes(int64(n))
+	}
+	return n, err
+}
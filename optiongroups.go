@@ -0,0 +1,154 @@
+package cmds
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// OptionGroupType defines how the options of an OptionGroup relate to each
+// other.
+type OptionGroupType int
+
+const (
+	// MutuallyExclusive groups contain options of which at most one may be set.
+	MutuallyExclusive OptionGroupType = iota
+	// RequiredTogether groups contain options that must be set all or none.
+	RequiredTogether
+	// Requires groups contain an option that can only be set if all other
+	// options of the group are set, too.
+	Requires
+)
+
+// OptionGroup declares a constraint on a set of options, referred to by
+// name. An option counts as set if it has a value other than its default.
+type OptionGroup struct {
+	Type  OptionGroupType
+	Names []string
+}
+
+// Exclusive returns an OptionGroup of options that can't be used together.
+func Exclusive(names ...string) OptionGroup {
+	return OptionGroup{Type: MutuallyExclusive, Names: names}
+}
+
+// Together returns an OptionGroup of options that have to be used together.
+func Together(names ...string) OptionGroup {
+	return OptionGroup{Type: RequiredTogether, Names: names}
+}
+
+// Require returns an OptionGroup stating that option name can only be used
+// in combination with the required options.
+func Require(name string, required ...string) OptionGroup {
+	return OptionGroup{Type: Requires, Names: append([]string{name}, required...)}
+}
+
+func (g OptionGroup) String() string {
+	flags := make([]string, len(g.Names))
+	for i, name := range g.Names {
+		flags[i] = optionFlag(name)
+	}
+
+	switch g.Type {
+	case MutuallyExclusive:
+		return fmt.Sprintf("%s are mutually exclusive", strings.Join(flags, ", "))
+	case RequiredTogether:
+		return fmt.Sprintf("%s must be used together", strings.Join(flags, ", "))
+	case Requires:
+		return fmt.Sprintf("%s requires %s", flags[0], strings.Join(flags[1:], ", "))
+	default:
+		return fmt.Sprintf("unknown option group type %d", g.Type)
+	}
+}
+
+// check returns a client error if the options set in opts violate the
+// constraint.
+func (g OptionGroup) check(opts cmdkit.OptMap, optDefs map[string]cmdkit.Option) error {
+	var set, unset []string
+	for _, name := range g.Names {
+		if isOptionSet(name, opts, optDefs) {
+			set = append(set, name)
+		} else {
+			unset = append(unset, name)
+		}
+	}
+
+	var violated bool
+	switch g.Type {
+	case MutuallyExclusive:
+		violated = len(set) > 1
+	case RequiredTogether:
+		violated = len(set) > 0 && len(unset) > 0
+	case Requires:
+		violated = len(g.Names) > 0 && isOptionSet(g.Names[0], opts, optDefs) && len(unset) > 0
+	}
+
+	if violated {
+		return ClientError(fmt.Sprintf("invalid combination of options: %s", g))
+	}
+	return nil
+}
+
+// isOptionSet returns whether opts contains a non-default value for the
+// option called name, using any of its names.
+func isOptionSet(name string, opts cmdkit.OptMap, optDefs map[string]cmdkit.Option) bool {
+	optDef, ok := optDefs[name]
+	if !ok {
+		_, ok = opts[name]
+		return ok
+	}
+
+	for _, n := range optDef.Names() {
+		if v, ok := opts[n]; ok && !reflect.DeepEqual(v, optDef.Default()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetOptionGroups returns the option groups in the given path of commands
+func (c *Command) GetOptionGroups(path []string) ([]OptionGroup, error) {
+	cmds, err := c.Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []OptionGroup
+	for _, cmd := range cmds {
+		groups = append(groups, cmd.OptionGroups...)
+	}
+
+	return groups, nil
+}
+
+// checkOptionGroups returns an error if the options set in the request
+// violate any of the option groups along the request path. Unless final is
+// set, values may still come from OptionSources, so only the mutually
+// exclusive groups, which more values can't satisfy, are checked.
+func (req *Request) checkOptionGroups(optDefs map[string]cmdkit.Option, final bool) error {
+	groups, err := req.Root.GetOptionGroups(req.Path)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		if !final && g.Type != MutuallyExclusive {
+			continue
+		}
+		if err := g.check(req.Options, optDefs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func optionFlag(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
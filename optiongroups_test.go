@@ -0,0 +1,137 @@
+package cmds
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+func TestOptionGroups(t *testing.T) {
+	root := &Command{
+		Options: []cmdkit.Option{
+			cmdkit.BoolOption("verbose", "v", "be verbose"),
+			cmdkit.BoolOption("quiet", "q", "be quiet"),
+		},
+		OptionGroups: []OptionGroup{
+			Exclusive("verbose", "quiet"),
+		},
+		Subcommands: map[string]*Command{
+			"sub": &Command{
+				Options: []cmdkit.Option{
+					cmdkit.StringOption("user", "the user"),
+					cmdkit.StringOption("password", "the password"),
+					cmdkit.StringOption("format", "the format").WithDefault("json"),
+					cmdkit.BoolOption("pretty", "pretty print"),
+				},
+				OptionGroups: []OptionGroup{
+					Together("user", "password"),
+					Require("pretty", "format"),
+				},
+				Run: noop,
+			},
+		},
+	}
+
+	type testcase struct {
+		opts map[string]interface{}
+		fail bool
+	}
+
+	tcs := []testcase{
+		{opts: map[string]interface{}{"verbose": true}},
+		{opts: map[string]interface{}{"verbose": true, "quiet": true}, fail: true},
+		{opts: map[string]interface{}{"v": true, "q": true}, fail: true},
+		{opts: map[string]interface{}{"user": "a", "password": "b"}},
+		{opts: map[string]interface{}{"user": "a"}, fail: true},
+		{opts: map[string]interface{}{"pretty": true, "format": "xml"}},
+		{opts: map[string]interface{}{"pretty": true}, fail: true},
+	}
+
+	for _, tc := range tcs {
+		req, err := NewRequest(context.Background(), []string{"sub"}, tc.opts, nil, nil, root)
+		if tc.fail {
+			if err == nil {
+				t.Errorf("%v: expected error", tc.opts)
+			} else if e, ok := err.(*cmdkit.Error); !ok || e.Code != cmdkit.ErrClient {
+				t.Errorf("%v: expected client error, got %v", tc.opts, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tc.opts, err)
+			continue
+		}
+
+		// filling in the defaults must not trigger a violation
+		if err = req.FillDefaults(); err != nil {
+			t.Errorf("%v: unexpected error %v", tc.opts, err)
+		}
+	}
+}
+
+func TestOptionGroupsSources(t *testing.T) {
+	os.Setenv("CMDSGROUPTEST_PASSWORD", "secret")
+	os.Setenv("CMDSGROUPTEST_QUIET", "true")
+	defer os.Unsetenv("CMDSGROUPTEST_PASSWORD")
+	defer os.Unsetenv("CMDSGROUPTEST_QUIET")
+
+	root := &Command{
+		Options: []cmdkit.Option{
+			cmdkit.StringOption("user", "the user"),
+			cmdkit.StringOption("password", "the password"),
+			cmdkit.BoolOption("verbose", "v", "be verbose"),
+			cmdkit.BoolOption("quiet", "q", "be quiet"),
+		},
+		OptionGroups: []OptionGroup{
+			Together("user", "password"),
+			Exclusive("verbose", "quiet"),
+		},
+		OptionSources: []OptionSource{NewEnvSource("CMDSGROUPTEST_")},
+		Run:           noop,
+	}
+
+	// the password comes from the environment
+	req, err := NewRequest(context.Background(), nil, map[string]interface{}{"user": "a"}, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.FillDefaults(); err != nil {
+		t.Errorf("expected the environment to complete the group, got %v", err)
+	}
+
+	// --quiet is set in the environment
+	req, err = NewRequest(context.Background(), nil, map[string]interface{}{"verbose": true}, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = req.FillDefaults()
+	if e, ok := err.(*cmdkit.Error); !ok || e.Code != cmdkit.ErrClient {
+		t.Errorf("expected client error for option in the environment, got %v", err)
+	}
+}
+
+func TestDebugValidateOptionGroups(t *testing.T) {
+	root := &Command{
+		Options: []cmdkit.Option{
+			cmdkit.BoolOption("a", "option a"),
+		},
+		Subcommands: map[string]*Command{
+			"sub": &Command{
+				Options: []cmdkit.Option{
+					cmdkit.BoolOption("b", "option b"),
+				},
+				OptionGroups: []OptionGroup{
+					Exclusive("a", "b"),
+					Exclusive("a", "c"),
+				},
+			},
+		},
+	}
+
+	errs := root.DebugValidate()
+	if len(errs) != 1 || len(errs["/sub"]) != 1 {
+		t.Errorf("expected one error for /sub, got %v", errs)
+	}
+}
@@ -0,0 +1,163 @@
+package cmds
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// SourceDefault is the name of the source of values taken from the default
+// value of the option definition.
+const SourceDefault = "default"
+
+// OptionSource provides values for options that have not been set by the
+// caller. The sources registered in the root command's OptionSources are
+// consulted in order by Request.FillDefaults before falling back to the
+// declared default values.
+type OptionSource interface {
+	// Name identifies the source, see Request.OptionSource.
+	Name() string
+
+	// Lookup returns the value of the option and whether the source has one.
+	Lookup(req *Request, opt cmdkit.Option) (interface{}, bool, error)
+}
+
+// EnvSource reads option values from environment variables.
+type EnvSource struct {
+	// Prefix is prepended to the variable names, e.g. "IPFS_".
+	Prefix string
+}
+
+// NewEnvSource returns an OptionSource that reads the value of an option from
+// the environment variable called prefix followed by the option's name in
+// upper case with dashes replaced by underscores, e.g. IPFS_STREAM_CHANNELS
+// for --stream-channels and prefix "IPFS_".
+func NewEnvSource(prefix string) *EnvSource {
+	return &EnvSource{Prefix: prefix}
+}
+
+// Name returns "env".
+func (s *EnvSource) Name() string {
+	return "env"
+}
+
+// VarName returns the name of the environment variable for opt.
+func (s *EnvSource) VarName(opt cmdkit.Option) string {
+	name := strings.ToUpper(strings.Replace(opt.Name(), "-", "_", -1))
+	return s.Prefix + name
+}
+
+// Lookup parses the value of the environment variable for opt, if set.
+func (s *EnvSource) Lookup(req *Request, opt cmdkit.Option) (interface{}, bool, error) {
+	str, ok := os.LookupEnv(s.VarName(opt))
+	if !ok {
+		return nil, false, nil
+	}
+
+	v, err := opt.Parse(str)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid value %q in environment variable %s: %s", str, s.VarName(opt), err)
+	}
+	return v, true, nil
+}
+
+// FileSource reads option values from a config file containing a map from
+// option names to values.
+type FileSource struct {
+	Path      string
+	Unmarshal func([]byte, interface{}) error
+
+	once   sync.Once
+	values map[string]interface{}
+	err    error
+}
+
+// NewFileSource returns an OptionSource that reads option values from the file
+// at path using unmarshal, e.g. json.Unmarshal or the Unmarshal function of a
+// TOML library. A missing file provides no values.
+func NewFileSource(path string, unmarshal func([]byte, interface{}) error) *FileSource {
+	return &FileSource{Path: path, Unmarshal: unmarshal}
+}
+
+// NewJSONFileSource returns a FileSource for the JSON file at path.
+func NewJSONFileSource(path string) *FileSource {
+	return NewFileSource(path, unmarshalJSONNumbers)
+}
+
+// unmarshalJSONNumbers is json.Unmarshal, but keeps numbers as json.Number so
+// that large integers don't lose precision.
+func unmarshalJSONNumbers(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// Name returns "file:" followed by the path of the file.
+func (s *FileSource) Name() string {
+	return "file:" + s.Path
+}
+
+func (s *FileSource) load() {
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		s.err = err
+		return
+	}
+
+	err = s.Unmarshal(data, &s.values)
+	if err != nil {
+		s.err = fmt.Errorf("could not parse config file %s: %s", s.Path, err)
+	}
+}
+
+// Lookup returns the value of opt from the config file, looked up by any of
+// its names. The file is read on the first call.
+func (s *FileSource) Lookup(req *Request, opt cmdkit.Option) (interface{}, bool, error) {
+	s.once.Do(s.load)
+	if s.err != nil {
+		return nil, false, s.err
+	}
+
+	for _, name := range opt.Names() {
+		v, ok := s.values[name]
+		if !ok {
+			continue
+		}
+
+		var str string
+		switch v := v.(type) {
+		case string:
+			str = v
+		case float64:
+			// fmt.Sprint would give 1e+06, which options can't parse
+			str = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			str = fmt.Sprint(v)
+		}
+
+		val, err := opt.Parse(str)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid value %q for option %q in config file %s: %s", str, name, s.Path, err)
+		}
+		return val, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// OptionSource returns the name of the source that supplied the value of the
+// option with the given name when filling in defaults. It returns the empty
+// string if the value has been set by the caller or the option is not set.
+func (req *Request) OptionSource(name string) string {
+	return req.optionSources[name]
+}
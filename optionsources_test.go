@@ -0,0 +1,134 @@
+package cmds
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+func TestOptionSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cmds-optionsources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(cfg, []byte(`{"depth": 3, "name": "file", "r": true}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CMDSTEST_NAME", "env")
+	defer os.Unsetenv("CMDSTEST_NAME")
+
+	root := &Command{
+		Options: []cmdkit.Option{
+			cmdkit.StringOption("name", "n", "").WithDefault("default"),
+			cmdkit.IntOption("depth", "").WithDefault(1),
+			cmdkit.BoolOption("recursive", "r", ""),
+			cmdkit.StringOption("color", "").WithDefault("auto"),
+			cmdkit.StringOption("other", ""),
+		},
+		OptionSources: []OptionSource{
+			NewEnvSource("CMDSTEST_"),
+			NewJSONFileSource(cfg),
+		},
+		Run: noop,
+	}
+
+	req, err := NewRequest(nil, nil, map[string]interface{}{"color": "never"}, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.FillDefaults(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]struct {
+		value  interface{}
+		source string
+	}{
+		"name":      {"env", "env"},
+		"depth":     {3, "file:" + cfg},
+		"recursive": {true, "file:" + cfg},
+		"color":     {"never", ""},
+	}
+	for name, exp := range expected {
+		if v := req.Options[name]; v != exp.value {
+			t.Errorf("expected option %q to be %v, got %v", name, exp.value, v)
+		}
+		if src := req.OptionSource(name); src != exp.source {
+			t.Errorf("expected option %q to come from %q, got %q", name, exp.source, src)
+		}
+	}
+	if _, ok := req.Options["other"]; ok {
+		t.Error("option without value in any source should not be set")
+	}
+
+	os.Setenv("CMDSTEST_DEPTH", "deep")
+	defer os.Unsetenv("CMDSTEST_DEPTH")
+
+	req, err = NewRequest(nil, nil, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.FillDefaults(); err == nil {
+		t.Error("expected error for unparsable environment variable")
+	}
+}
+
+func TestFileSourceMissing(t *testing.T) {
+	src := NewJSONFileSource(filepath.Join(os.TempDir(), "cmds-does-not-exist.json"))
+	_, ok, err := src.Lookup(nil, cmdkit.StringOption("name", ""))
+	if err != nil || ok {
+		t.Errorf("expected no value and no error for missing file, got %v, %v", ok, err)
+	}
+}
+
+func TestFileSourceNumbers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cmds-optionsources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(cfg, []byte(`{"size": 1000000, "max": 18446744073709551615, "ratio": 0.25}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		opt cmdkit.Option
+		exp interface{}
+	}{
+		{cmdkit.IntOption("size", ""), 1000000},
+		{cmdkit.FloatOption("ratio", ""), 0.25},
+		// only exact with json.Number
+		{cmdkit.Uint64Option("max", ""), uint64(18446744073709551615)},
+	}
+
+	// plain json.Unmarshal stands in for unmarshalers that return float64
+	sources := map[*FileSource][]int{
+		NewJSONFileSource(cfg):             {0, 1, 2},
+		NewFileSource(cfg, json.Unmarshal): {0, 1},
+	}
+	for src, idx := range sources {
+		for _, i := range idx {
+			tc := tcs[i]
+			v, ok, err := src.Lookup(nil, tc.opt)
+			if err != nil || !ok {
+				t.Errorf("looking up %q: %v, %v", tc.opt.Name(), ok, err)
+				continue
+			}
+			if v != tc.exp {
+				t.Errorf("expected %q to be %v, got %v", tc.opt.Name(), tc.exp, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package cmds
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ProgressUnit is what the progress of a command is counted in.
+type ProgressUnit string
+
+const (
+	ProgressBytes ProgressUnit = "bytes"
+	ProgressItems ProgressUnit = "items"
+)
+
+// Progress describes how much of the work of a command is done. It is sent
+// besides the values a command emits, see SetProgress.
+type Progress struct {
+	Done uint64
+
+	// Total is the amount of work to do, zero if unknown. If it is not set,
+	// emitters use the length set with SetLength.
+	Total uint64
+
+	Unit ProgressUnit
+}
+
+// MarshalJSON marks the encoded value as progress, so it can be told apart
+// from the values of a command. This is how cmdkit.Error does it, too.
+func (p Progress) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Done  uint64
+		Total uint64
+		Unit  ProgressUnit
+		Type  string
+	}{p.Done, p.Total, p.Unit, "progress"})
+}
+
+func (p *Progress) UnmarshalJSON(data []byte) error {
+	var w struct {
+		Done  uint64
+		Total uint64
+		Unit  ProgressUnit
+		Type  string
+	}
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	if w.Type != "progress" {
+		return errors.New("not of type progress")
+	}
+
+	p.Done, p.Total, p.Unit = w.Done, w.Total, w.Unit
+	return nil
+}
+
+// ProgressEmitter is a ResponseEmitter that can report progress to the
+// client besides the emitted values.
+type ProgressEmitter interface {
+	ResponseEmitter
+
+	// SetProgress reports the progress of the command.
+	SetProgress(Progress) error
+}
+
+// ProgressResponse is a Response that receives progress reports. They are
+// passed to a callback instead of being returned by Next.
+type ProgressResponse interface {
+	Response
+
+	// OnProgress sets the function that is called with the progress
+	// received while reading the values with Next or RawNext.
+	OnProgress(func(Progress))
+}
+
+// SetProgress reports the progress of the command on re if it supports it,
+// and does nothing otherwise. Emit the results of the command as usual.
+func SetProgress(re ResponseEmitter, p Progress) error {
+	pe, ok := re.(ProgressEmitter)
+	if !ok {
+		return nil
+	}
+
+	return pe.SetProgress(p)
+}
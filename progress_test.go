@@ -0,0 +1,88 @@
+package cmds
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestProgressJSON(t *testing.T) {
+	p := Progress{Done: 5, Total: 10, Unit: ProgressBytes}
+
+	buf, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"Done":5,"Total":10,"Unit":"bytes","Type":"progress"}`; string(buf) != exp {
+		t.Errorf("expected %s, got %s", exp, buf)
+	}
+
+	m := &MaybeError{Value: &Progress{}}
+	if err := json.Unmarshal(buf, m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.Get(); v != p {
+		t.Errorf("expected %v, got %v", p, v)
+	}
+
+	// values with the same fields are no progress
+	var value struct{ Done, Total int }
+	m = &MaybeError{Value: &value}
+	if err := json.Unmarshal([]byte(`{"Done":1,"Total":2}`), m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Get().(Progress); ok || value.Total != 2 {
+		t.Errorf("expected value, got %#v", m.Get())
+	}
+}
+
+func TestChanProgress(t *testing.T) {
+	req := &Request{}
+	re, res := NewChanResponsePair(req)
+	copyRe, copyRes := NewChanResponsePair(req)
+
+	var progress []Progress
+	copyRes.(ProgressResponse).OnProgress(func(p Progress) {
+		progress = append(progress, p)
+	})
+
+	go func() {
+		re.SetLength(3)
+		SetProgress(re, Progress{Done: 1})
+		re.Emit("a")
+		SetProgress(re, Progress{Done: 3, Total: 4, Unit: ProgressItems})
+		re.Emit("b")
+		re.Close()
+	}()
+	go func() {
+		if err := Copy(copyRe, res); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var values []interface{}
+	for {
+		v, err := copyRes.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+
+	if exp := []interface{}{"a", "b"}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("expected values %v, got %v", exp, values)
+	}
+	if exp := []Progress{{Done: 1, Total: 3}, {Done: 3, Total: 4, Unit: ProgressItems}}; !reflect.DeepEqual(progress, exp) {
+		t.Errorf("expected progress %v, got %v", exp, progress)
+	}
+
+	// emitters without progress support ignore it
+	w := &closeNotifier{closed: make(chan struct{})}
+	if err := SetProgress(NewWriterResponseEmitter(w, req, Encoders[JSON]), Progress{}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,339 @@
+package cmds
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// ProtoMessage is a protobuf message that marshals itself, like the types
+// generated by gogo/protobuf. Commands whose Type, or a pointer to it, is a
+// ProtoMessage can be sent with the Protobuf encoding.
+type ProtoMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// The Protobuf encoding sends every value in a frame, prefixed with its
+// length as a varint like protobuf's delimited streams. A frame is the
+// message
+//
+//	message Frame {
+//		bytes value = 1;
+//		Error error = 2;
+//		Progress progress = 3;
+//		Warning warning = 4;
+//	}
+//
+//	message Error {
+//		string message = 1;
+//		uint64 code = 2;
+//	}
+//
+//	message Progress {
+//		uint64 done = 1;
+//		uint64 total = 2;
+//		string unit = 3;
+//	}
+//
+//	message Warning {
+//		string message = 1;
+//	}
+//
+// where value is the marshaled value and exactly one of the fields is set.
+const (
+	frameValue    = 1
+	frameError    = 2
+	frameProgress = 3
+	frameWarning  = 4
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// maxFrameSize limits the memory a decoder allocates for a frame.
+const maxFrameSize = 64 << 20
+
+type protoEncoder struct {
+	w io.Writer
+}
+
+func (e *protoEncoder) Encode(v interface{}) error {
+	if s, ok := v.(Single); ok {
+		v = s.Value
+	}
+
+	var frame []byte
+	switch val := v.(type) {
+	case cmdkit.Error:
+		frame = appendBytes(nil, frameError, marshalProtoError(&val))
+	case *cmdkit.Error:
+		frame = appendBytes(nil, frameError, marshalProtoError(val))
+	case Progress:
+		var b []byte
+		b = appendVarintField(b, 1, val.Done)
+		b = appendVarintField(b, 2, val.Total)
+		b = appendBytes(b, 3, []byte(val.Unit))
+		frame = appendBytes(nil, frameProgress, b)
+	case Warning:
+		frame = appendBytes(nil, frameWarning, appendBytes(nil, 1, []byte(val.Message)))
+	default:
+		msg, ok := protoMessage(v)
+		if !ok {
+			return fmt.Errorf("value of type %T is not a protobuf message", v)
+		}
+		b, err := msg.Marshal()
+		if err != nil {
+			return err
+		}
+		frame = appendBytes(nil, frameValue, b)
+	}
+
+	_, err := e.w.Write(append(appendVarint(nil, uint64(len(frame))), frame...))
+	return err
+}
+
+// IsProtoMessage reports whether v can be sent with the Protobuf encoding,
+// i.e. whether v or a pointer to it is a ProtoMessage.
+func IsProtoMessage(v interface{}) bool {
+	_, ok := protoMessage(v)
+	return ok
+}
+
+// protoMessage returns v as a ProtoMessage, also if only a pointer to it is
+// one.
+func protoMessage(v interface{}) (ProtoMessage, bool) {
+	if msg, ok := v.(ProtoMessage); ok {
+		return msg, true
+	}
+	if v == nil {
+		return nil, false
+	}
+
+	p := reflect.New(reflect.TypeOf(v))
+	p.Elem().Set(reflect.ValueOf(v))
+	msg, ok := p.Interface().(ProtoMessage)
+	return msg, ok
+}
+
+func marshalProtoError(e *cmdkit.Error) []byte {
+	var b []byte
+	b = appendBytes(b, 1, []byte(e.Message))
+	b = appendVarintField(b, 2, uint64(e.Code))
+	return b
+}
+
+type protoDecoder struct {
+	r *bufio.Reader
+}
+
+// Decode reads the next frame into v, which is a *MaybeError, a
+// *cmdkit.Error or **cmdkit.Error for error frames, or a ProtoMessage for
+// values.
+func (d *protoDecoder) Decode(v interface{}) error {
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err
+	}
+	if size > maxFrameSize {
+		return fmt.Errorf("protobuf frame of %d bytes is too large", size)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	var (
+		kind int
+		data []byte
+	)
+	err = parseProto(buf, func(field, wire int, _ uint64, b []byte) error {
+		if wire == wireBytes && field >= frameValue && field <= frameWarning {
+			kind, data = field, b
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case frameValue:
+		return d.decodeValue(v, data)
+	case frameError:
+		e, err := unmarshalProtoError(data)
+		if err != nil {
+			return err
+		}
+		switch dst := v.(type) {
+		case *MaybeError:
+			dst.Error, dst.isError = *e, true
+		case *cmdkit.Error:
+			*dst = *e
+		case **cmdkit.Error:
+			*dst = e
+		default:
+			return e
+		}
+		return nil
+	case frameProgress:
+		m, ok := v.(*MaybeError)
+		if !ok {
+			return errors.New("unexpected progress frame")
+		}
+		m.Progress, m.isProgress = Progress{}, true
+		return parseProto(data, func(field, wire int, x uint64, b []byte) error {
+			switch field {
+			case 1:
+				m.Progress.Done = x
+			case 2:
+				m.Progress.Total = x
+			case 3:
+				m.Progress.Unit = ProgressUnit(b)
+			}
+			return nil
+		})
+	case frameWarning:
+		m, ok := v.(*MaybeError)
+		if !ok {
+			return errors.New("unexpected warning frame")
+		}
+		m.Warning, m.isWarning = Warning{}, true
+		return parseProto(data, func(field, wire int, x uint64, b []byte) error {
+			if field == 1 {
+				m.Warning.Message = string(b)
+			}
+			return nil
+		})
+	default:
+		return errors.New("protobuf frame without content")
+	}
+}
+
+func (d *protoDecoder) decodeValue(v interface{}, data []byte) error {
+	m, ok := v.(*MaybeError)
+	if !ok {
+		msg, ok := v.(ProtoMessage)
+		if !ok {
+			return fmt.Errorf("can't decode protobuf value into %T", v)
+		}
+		return msg.Unmarshal(data)
+	}
+
+	// decode into a new value, m.Value may be the Type of the command
+	t := reflect.TypeOf(m.Value)
+	if t == nil {
+		return errors.New("can't decode protobuf value without a type")
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	p := reflect.New(t)
+	msg, ok := p.Interface().(ProtoMessage)
+	if !ok {
+		return fmt.Errorf("can't decode protobuf value into %s", p.Type())
+	}
+	if err := msg.Unmarshal(data); err != nil {
+		return err
+	}
+
+	m.Value = p.Interface()
+	return nil
+}
+
+func unmarshalProtoError(data []byte) (*cmdkit.Error, error) {
+	e := &cmdkit.Error{}
+	err := parseProto(data, func(field, wire int, x uint64, b []byte) error {
+		switch field {
+		case 1:
+			e.Message = string(b)
+		case 2:
+			e.Code = cmdkit.ErrorType(x)
+		}
+		return nil
+	})
+	return e, err
+}
+
+// parseProto calls f with every field of a protobuf message. x is the value
+// of varint fields, b the data of length-delimited ones. Fixed-size fields
+// are skipped.
+func parseProto(data []byte, f func(field, wire int, x uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("invalid protobuf field key")
+		}
+		data = data[n:]
+
+		field, wire := int(key>>3), int(key&7)
+		var (
+			x uint64
+			b []byte
+		)
+		switch wire {
+		case wireVarint:
+			x, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errors.New("invalid protobuf varint")
+			}
+			data = data[n:]
+		case wireBytes:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || l > uint64(len(data)-n) {
+				return errors.New("invalid protobuf length")
+			}
+			b = data[n : n+int(l)]
+			data = data[n+int(l):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wire == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return errors.New("truncated protobuf field")
+			}
+			data = data[size:]
+			continue
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wire)
+		}
+
+		if err := f(field, wire, x, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendVarint(b []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	return append(b, buf[:n]...)
+}
+
+func appendVarintField(b []byte, field int, x uint64) []byte {
+	b = appendVarint(b, uint64(field)<<3|wireVarint)
+	return appendVarint(b, x)
+}
+
+func appendBytes(b []byte, field int, data []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
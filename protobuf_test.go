@@ -0,0 +1,119 @@
+package cmds
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// protoValue is a hand-written protobuf message
+//
+//	message protoValue {
+//		string name = 1;
+//		uint64 n = 2;
+//	}
+type protoValue struct {
+	Name string
+	N    uint64
+}
+
+func (v *protoValue) Marshal() ([]byte, error) {
+	if v.Name == "bad" {
+		return nil, errors.New("can't marshal bad values")
+	}
+	return appendVarintField(appendBytes(nil, 1, []byte(v.Name)), 2, v.N), nil
+}
+
+func (v *protoValue) Unmarshal(data []byte) error {
+	*v = protoValue{}
+	return parseProto(data, func(field, wire int, x uint64, b []byte) error {
+		switch field {
+		case 1:
+			v.Name = string(b)
+		case 2:
+			v.N = x
+		}
+		return nil
+	})
+}
+
+func TestProtobufEncoding(t *testing.T) {
+	req := &Request{Command: &Command{Type: &protoValue{}}, Options: cmdkit.OptMap{EncLong: Protobuf}}
+	r, w := io.Pipe()
+	re := NewWriterResponseEmitter(w, req, Encoders[Protobuf])
+	res := NewReaderResponse(r, Protobuf, req)
+
+	go func() {
+		re.Emit(&protoValue{Name: "a", N: 1})
+		Warn(re, "careful")
+		// values are encoded if only their pointer is a message
+		re.Emit(protoValue{Name: "b", N: 300})
+		re.Emit(&protoValue{})
+		re.SetError("boom", cmdkit.ErrClient)
+		re.Close()
+	}()
+
+	var values []protoValue
+	err := Collect(res, &values)
+	if e, ok := err.(*cmdkit.Error); !ok || e.Message != "boom" || e.Code != cmdkit.ErrClient {
+		t.Errorf("expected the command error, got %#v", err)
+	}
+
+	exp := []protoValue{{"a", 1}, {"b", 300}, {}}
+	if !reflect.DeepEqual(values, exp) {
+		t.Errorf("expected values %v, got %v", exp, values)
+	}
+	if ws := res.(WarningResponse).Warnings(); !reflect.DeepEqual(ws, []string{"careful"}) {
+		t.Errorf("expected warning, got %v", ws)
+	}
+
+	// the type of the command is not decoded into
+	if *req.Command.Type.(*protoValue) != (protoValue{}) {
+		t.Errorf("expected the command type to be unchanged, got %v", req.Command.Type)
+	}
+}
+
+func TestProtobufEncodingErrors(t *testing.T) {
+	var buf bytes.Buffer
+	enc := Encoders[Protobuf](nil)(&buf)
+
+	if err := enc.Encode("text"); err == nil {
+		t.Error("expected error for value that is not a message")
+	}
+	if err := enc.Encode(&protoValue{Name: "bad"}); err == nil {
+		t.Error("expected error of Marshal")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be written, got %x", buf.Bytes())
+	}
+
+	// errors can be decoded without MaybeError
+	if err := enc.Encode(&cmdkit.Error{Message: "boom", Code: cmdkit.ErrFatal}); err != nil {
+		t.Fatal(err)
+	}
+	e := &cmdkit.Error{}
+	if err := Decoders[Protobuf](&buf).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Message != "boom" || e.Code != cmdkit.ErrFatal {
+		t.Errorf("expected error boom, got %#v", e)
+	}
+
+	// truncated frames
+	enc.Encode(&protoValue{Name: "a"})
+	buf.Truncate(buf.Len() - 1)
+	if err := Decoders[Protobuf](&buf).Decode(&MaybeError{Value: &protoValue{}}); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	// values need a type
+	buf.Reset()
+	enc.Encode(&protoValue{Name: "a"})
+	if err := Decoders[Protobuf](&buf).Decode(&MaybeError{}); err == nil {
+		t.Error("expected error for value without type")
+	}
+}
@@ -1,11 +1,20 @@
 package cmds
 
 import (
+	"context"
+	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
 )
 
+// DefaultReqLogSize is the number of entries a ReqLog keeps unless set
+// otherwise with SetMaxEntries.
+const DefaultReqLogSize = 1000
+
 type ReqLogEntry struct {
 	StartTime time.Time
 	EndTime   time.Time
@@ -14,6 +23,25 @@ type ReqLogEntry struct {
 	Options   map[string]interface{}
 	Args      []string
 	ID        int
+
+	// Queued is set while the request waits to be admitted, e.g. because of
+	// concurrency limits. Waited is how long it waited.
+	Queued bool
+	Waited time.Duration
+
+	// Canceled is set if the request was cancelled with ReqLog.Cancel.
+	Canceled bool
+
+	// Error is the error the request failed with, Values the number of
+	// values it emitted and Bytes the size of the raw data among them, i.e.
+	// of []byte, string and io.Reader values. They are only recorded by the
+	// ReqLog's Middleware, without it they stay empty.
+	Error  string `json:",omitempty"`
+	Values int
+	Bytes  int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func (r *ReqLogEntry) Copy() *ReqLogEntry {
@@ -21,39 +49,191 @@ func (r *ReqLogEntry) Copy() *ReqLogEntry {
 	return &out
 }
 
+// Context returns the context of the logged request. It is derived from the
+// context the request had when it was added, is cancelled by ReqLog.Cancel
+// and lets the ReqLog's Middleware find the entry. Run the request with it.
+func (r *ReqLogEntry) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// ReqLog records the requests of a daemon. Running requests are kept until
+// they finish, finished ones in a ring of fixed size, see SetMaxEntries, and
+// only as long as the keep time, see SetKeepTime.
+//
+// Add and Finish don't see what a command emits: Error, Values and Bytes of
+// the entries are only recorded if the ReqLog's Middleware is installed on
+// the root command.
 type ReqLog struct {
-	Requests []*ReqLogEntry
-	nextID   int
-	lock     sync.Mutex
-	keep     time.Duration
+	nextID int
+	lock   sync.Mutex
+	keep   time.Duration
+	max    int
+	store  ReqLogStore
+
+	// active holds the running requests in the order they were added.
+	// finished is the ring of finished requests, n of them starting at
+	// head, in the order they finished.
+	active   []*ReqLogEntry
+	finished []*ReqLogEntry
+	head, n  int
 }
 
+// Add adds req to the log as running. req is not modified; set its context to
+// the Context of the returned entry so that Cancel and Middleware work.
 func (rl *ReqLog) Add(req *Request) *ReqLogEntry {
+	rle := rl.entry(req)
+	rl.add(rle)
+	return rle
+}
+
+// AddQueued adds req to the log as waiting to be admitted. Call Start when
+// it starts running.
+func (rl *ReqLog) AddQueued(req *Request) *ReqLogEntry {
+	rle := rl.entry(req)
+	rle.Queued = true
+	rl.add(rle)
+	return rle
+}
+
+// add adds rle with the next ID.
+func (rl *ReqLog) add(rle *ReqLogEntry) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	rle.ID = rl.nextID
+	rl.addEntry(rle)
+}
+
+func (rl *ReqLog) entry(req *Request) *ReqLogEntry {
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
 	rle := &ReqLogEntry{
 		StartTime: time.Now(),
 		Active:    true,
 		Command:   strings.Join(req.Path, "/"),
 		Options:   req.Options,
 		Args:      req.Arguments,
-		ID:        rl.nextID,
+		cancel:    cancel,
 	}
+	rle.ctx = context.WithValue(ctx, reqLogKey{rl}, rle)
 
-	rl.AddEntry(rle)
 	return rle
 }
 
+// Start marks a queued entry as running.
+func (rl *ReqLog) Start(rle *ReqLogEntry) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	now := time.Now()
+	rle.Queued = false
+	rle.Waited = now.Sub(rle.StartTime)
+	rle.StartTime = now
+}
+
 func (rl *ReqLog) AddEntry(rle *ReqLogEntry) {
 	rl.lock.Lock()
 	defer rl.lock.Unlock()
 
+	rl.addEntry(rle)
+}
+
+func (rl *ReqLog) addEntry(rle *ReqLogEntry) {
 	rl.nextID++
-	rl.Requests = append(rl.Requests, rle)
+	if rle == nil {
+		return
+	}
 
-	if rle == nil || !rle.Active {
-		rl.maybeCleanup()
+	if rle.Active {
+		rl.active = append(rl.active, rle)
+		return
 	}
 
-	return
+	rl.push(rle)
+	rl.maybeCleanup()
+}
+
+// SetMaxEntries sets the number of entries the log keeps. If there are more,
+// the oldest finished ones are dropped. Active entries are never dropped.
+func (rl *ReqLog) SetMaxEntries(n int) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	rl.max = n
+	rl.resize()
+	rl.trim()
+}
+
+// size returns the maximum number of entries.
+func (rl *ReqLog) size() int {
+	if rl.max <= 0 {
+		return DefaultReqLogSize
+	}
+	return rl.max
+}
+
+// resize allocates the ring for the maximum number of entries, keeping the
+// newest finished entries.
+func (rl *ReqLog) resize() {
+	entries := rl.finishedEntries()
+	if len(entries) > rl.size() {
+		entries = entries[len(entries)-rl.size():]
+	}
+
+	rl.finished = make([]*ReqLogEntry, rl.size())
+	rl.head, rl.n = 0, copy(rl.finished, entries)
+}
+
+// push adds a finished entry to the ring.
+func (rl *ReqLog) push(rle *ReqLogEntry) {
+	if len(rl.finished) != rl.size() {
+		rl.resize()
+	}
+
+	if rl.n == len(rl.finished) {
+		rl.pop()
+	}
+	rl.finished[(rl.head+rl.n)%len(rl.finished)] = rle
+	rl.n++
+	rl.trim()
+}
+
+// pop drops the oldest finished entry.
+func (rl *ReqLog) pop() {
+	rl.finished[rl.head] = nil
+	rl.head = (rl.head + 1) % len(rl.finished)
+	rl.n--
+}
+
+// trim drops the oldest finished entries beyond the maximum size.
+func (rl *ReqLog) trim() {
+	for rl.n > 0 && rl.n+len(rl.active) > rl.size() {
+		rl.pop()
+	}
+}
+
+// finishedEntries returns the finished entries in the order they finished.
+func (rl *ReqLog) finishedEntries() []*ReqLogEntry {
+	out := make([]*ReqLogEntry, rl.n)
+	for i := range out {
+		out[i] = rl.finished[(rl.head+i)%len(rl.finished)]
+	}
+	return out
+}
+
+// entries returns all entries ordered by ID.
+func (rl *ReqLog) entries() []*ReqLogEntry {
+	out := append(rl.finishedEntries(), rl.active...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
 }
 
 func (rl *ReqLog) ClearInactive() {
@@ -68,22 +248,35 @@ func (rl *ReqLog) ClearInactive() {
 func (rl *ReqLog) maybeCleanup() {
 	// only do it every so often or it might
 	// become a perf issue
-	if len(rl.Requests)%10 == 0 {
+	if (rl.n+len(rl.active))%10 == 0 {
 		rl.cleanup()
 	}
 }
 
+// cleanup drops the finished entries older than the keep time. The ring is
+// in the order the entries finished, so they are dropped from its start.
 func (rl *ReqLog) cleanup() {
-	i := 0
 	now := time.Now()
-	for j := 0; j < len(rl.Requests); j++ {
-		rj := rl.Requests[j]
-		if rj.Active || rl.Requests[j].EndTime.Add(rl.keep).After(now) {
-			rl.Requests[i] = rl.Requests[j]
+	expired := func(rle *ReqLogEntry) bool {
+		return !rle.Active && !rle.EndTime.Add(rl.keep).After(now)
+	}
+
+	for rl.n > 0 && expired(rl.finished[rl.head]) {
+		rl.pop()
+	}
+
+	// entries that have been marked as inactive without Finish
+	i := 0
+	for _, rle := range rl.active {
+		if !expired(rle) {
+			rl.active[i] = rle
 			i++
 		}
 	}
-	rl.Requests = rl.Requests[:i]
+	for j := i; j < len(rl.active); j++ {
+		rl.active[j] = nil
+	}
+	rl.active = rl.active[:i]
 }
 
 func (rl *ReqLog) SetKeepTime(t time.Duration) {
@@ -96,9 +289,8 @@ func (rl *ReqLog) SetKeepTime(t time.Duration) {
 func (rl *ReqLog) Report() []*ReqLogEntry {
 	rl.lock.Lock()
 	defer rl.lock.Unlock()
-	out := make([]*ReqLogEntry, len(rl.Requests))
-
-	for i, e := range rl.Requests {
+	out := rl.entries()
+	for i, e := range out {
 		out[i] = e.Copy()
 	}
 
@@ -107,10 +299,163 @@ func (rl *ReqLog) Report() []*ReqLogEntry {
 
 func (rl *ReqLog) Finish(rle *ReqLogEntry) {
 	rl.lock.Lock()
-	defer rl.lock.Unlock()
+
+	if rle.cancel != nil {
+		rle.cancel()
+	}
 
 	rle.Active = false
 	rle.EndTime = time.Now()
 
+	for i, a := range rl.active {
+		if a == rle {
+			rl.active = append(rl.active[:i], rl.active[i+1:]...)
+			rl.push(rle)
+			break
+		}
+	}
+
 	rl.maybeCleanup()
+
+	store, done := rl.store, rle.Copy()
+	rl.lock.Unlock()
+
+	if store != nil {
+		if err := store.Append(done); err != nil {
+			log.Errorf("failed to store request log entry %d: %s", done.ID, err)
+		}
+	}
+}
+
+// Cancel cancels the context of the active request with the given ID.
+func (rl *ReqLog) Cancel(id int) error {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	for _, rle := range rl.entries() {
+		if rle.ID != id {
+			continue
+		}
+		if !rle.Active {
+			return fmt.Errorf("request %d is not active", id)
+		}
+		if rle.cancel == nil {
+			return fmt.Errorf("request %d can't be cancelled", id)
+		}
+
+		rle.cancel()
+		rle.Canceled = true
+		return nil
+	}
+
+	return fmt.Errorf("no request with ID %d", id)
+}
+
+// ReqLogQuery selects entries of a ReqLog. The zero value selects all.
+type ReqLogQuery struct {
+	// Command selects requests for the command at this path, e.g. "block/rm",
+	// and its subcommands.
+	Command string
+
+	// Since and Until select requests started in this time range. Zero means
+	// no bound.
+	Since, Until time.Time
+
+	// Active and Finished select active or finished requests. If neither is
+	// set, both are selected.
+	Active, Finished bool
+
+	// Failed and Succeeded select requests with or without error. If neither
+	// is set, both are selected.
+	Failed, Succeeded bool
+}
+
+// Match reports whether rle is selected by q.
+func (q *ReqLogQuery) Match(rle *ReqLogEntry) bool {
+	if q.Command != "" && rle.Command != q.Command && !strings.HasPrefix(rle.Command, q.Command+"/") {
+		return false
+	}
+	if !q.Since.IsZero() && rle.StartTime.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !rle.StartTime.Before(q.Until) {
+		return false
+	}
+	if q.Active != q.Finished && rle.Active != q.Active {
+		return false
+	}
+	if failed := rle.Error != ""; q.Failed != q.Succeeded && failed != q.Failed {
+		return false
+	}
+	return true
+}
+
+// Query returns a copy of the entries selected by q, oldest first.
+func (rl *ReqLog) Query(q ReqLogQuery) []*ReqLogEntry {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	var out []*ReqLogEntry
+	for _, e := range rl.entries() {
+		if q.Match(e) {
+			out = append(out, e.Copy())
+		}
+	}
+
+	return out
+}
+
+type reqLogKey struct {
+	rl *ReqLog
+}
+
+// Middleware returns a Middleware that records the error and the values
+// emitted by requests added to rl in their entries, provided they run with
+// the Context of their entry. Use it as the Middleware of the root command.
+// Note that commands then no longer see the other methods of the
+// ResponseEmitter passed to them, except for SetEncoder, SetProgress, Warn,
+// Type, Head and Flush.
+func (rl *ReqLog) Middleware() Middleware {
+	return func(next Executor) Executor {
+		return ExecutorFunc(func(req *Request, re ResponseEmitter, env Environment) error {
+			rle, ok := req.Context.Value(reqLogKey{rl}).(*ReqLogEntry)
+			if !ok {
+				return next.Execute(req, re, env)
+			}
+
+			obs := reqLogObserver{rl, rle}
+			err := next.Execute(req, observeEmitter(re, obs), env)
+			if err != nil {
+				obs.observeError(err, cmdkit.ErrNormal)
+			}
+			return err
+		})
+	}
+}
+
+// reqLogObserver records what is emitted for a request in its entry.
+type reqLogObserver struct {
+	rl  *ReqLog
+	rle *ReqLogEntry
+}
+
+func (o reqLogObserver) observeError(err interface{}, code cmdkit.ErrorType) {
+	o.rl.lock.Lock()
+	defer o.rl.lock.Unlock()
+
+	o.rle.Error = fmt.Sprint(err)
+}
+
+func (o reqLogObserver) observeValue() {
+	o.rl.lock.Lock()
+	defer o.rl.lock.Unlock()
+
+	o.rle.Values++
+}
+
+func (o reqLogObserver) observeBytes(n int64) {
+	o.rl.lock.Lock()
+	defer o.rl.lock.Unlock()
+
+	o.rle.Bytes += n
 }
@@ -1,7 +1,18 @@
 package cmds
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
 )
 
 func TestReqLog(t *testing.T) {
@@ -36,3 +47,253 @@ func TestReqLog(t *testing.T) {
 	}
 
 }
+
+func TestReqLogQueued(t *testing.T) {
+	l := &ReqLog{}
+
+	rle := l.AddQueued(&Request{Path: []string{"test"}})
+	if r := l.Report()[0]; !r.Active || !r.Queued {
+		t.Fatalf("expected active queued entry, got %+v", r)
+	}
+
+	l.Start(rle)
+	if r := l.Report()[0]; !r.Active || r.Queued || r.Waited < 0 {
+		t.Fatalf("expected running entry, got %+v", r)
+	}
+
+	l.Finish(rle)
+	if r := l.Report()[0]; r.Active {
+		t.Fatalf("expected finished entry, got %+v", r)
+	}
+}
+
+func TestReqLogCancel(t *testing.T) {
+	l := &ReqLog{}
+
+	req := &Request{Context: context.Background()}
+	rle := l.Add(req)
+
+	if err := l.Cancel(rle.ID + 1); err == nil {
+		t.Error("expected error for unknown ID")
+	}
+
+	if err := l.Cancel(rle.ID); err != nil {
+		t.Fatal(err)
+	}
+	if req.Context.Err() != nil {
+		t.Errorf("expected the context of the request to be left alone, got %v", req.Context.Err())
+	}
+	if rle.Context().Err() != context.Canceled {
+		t.Errorf("expected entry context to be cancelled, got %v", rle.Context().Err())
+	}
+	if r := l.Report()[0]; !r.Canceled {
+		t.Errorf("expected entry to be marked as cancelled, got %+v", r)
+	}
+
+	l.Finish(rle)
+	if err := l.Cancel(rle.ID); err == nil {
+		t.Error("expected error for finished request")
+	}
+}
+
+func TestReqLogCommand(t *testing.T) {
+	l := &ReqLog{}
+	cmd := ReqLogCommand(l)
+	if findings := Lint(cmd); len(findings) != 0 {
+		t.Errorf("unexpected lint findings: %v", findings)
+	}
+
+	now := time.Now()
+	l.Add(&Request{Path: []string{"slow"}}).StartTime = now.Add(-time.Second)
+	done := l.Add(&Request{Path: []string{"fast"}})
+	l.Finish(done)
+	done.StartTime, done.EndTime = now.Add(-time.Minute), now.Add(-time.Minute+time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := writeReqLog(&buf, l.Report(), false, now); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `ID  State    Command  Duration
+0   running  slow     1s
+1   done     fast     1ms
+`
+	if buf.String() != exp {
+		t.Errorf("expected\n%s\ngot\n%s", exp, buf.String())
+	}
+}
+
+func TestReqLogMaxEntries(t *testing.T) {
+	l := &ReqLog{}
+	l.SetKeepTime(time.Hour)
+	l.SetMaxEntries(3)
+
+	active := l.Add(&Request{Path: []string{"active"}})
+	for i := 0; i < 5; i++ {
+		l.Finish(l.Add(&Request{Path: []string{"done"}}))
+	}
+
+	entries := l.Report()
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if entries[0].ID != active.ID || entries[1].ID != 4 || entries[2].ID != 5 {
+		t.Errorf("expected the active and the newest entries, got IDs %d, %d and %d", entries[0].ID, entries[1].ID, entries[2].ID)
+	}
+}
+
+func TestReqLogQuery(t *testing.T) {
+	l := &ReqLog{}
+	l.SetKeepTime(time.Hour)
+
+	now := time.Now()
+	add := func(path string, age time.Duration, active bool, err string) {
+		rle := l.Add(&Request{Path: strings.Split(path, "/")})
+		if !active {
+			l.Finish(rle)
+		}
+		rle.StartTime = now.Add(-age)
+		rle.Error = err
+	}
+	add("block/rm", time.Hour, false, "")
+	add("block/get", time.Minute, false, "not found")
+	add("blockchain", time.Second, true, "")
+	add("block", 0, true, "")
+
+	ids := func(entries []*ReqLogEntry) []int {
+		out := []int{}
+		for _, e := range entries {
+			out = append(out, e.ID)
+		}
+		return out
+	}
+
+	tcs := []struct {
+		q   ReqLogQuery
+		exp []int
+	}{
+		{ReqLogQuery{}, []int{0, 1, 2, 3}},
+		{ReqLogQuery{Command: "block"}, []int{0, 1, 3}},
+		{ReqLogQuery{Command: "block/rm"}, []int{0}},
+		{ReqLogQuery{Since: now.Add(-2 * time.Minute)}, []int{1, 2, 3}},
+		{ReqLogQuery{Until: now.Add(-time.Second)}, []int{0, 1}},
+		{ReqLogQuery{Active: true}, []int{2, 3}},
+		{ReqLogQuery{Finished: true}, []int{0, 1}},
+		{ReqLogQuery{Active: true, Finished: true}, []int{0, 1, 2, 3}},
+		{ReqLogQuery{Failed: true}, []int{1}},
+		{ReqLogQuery{Succeeded: true, Command: "block"}, []int{0, 3}},
+	}
+
+	for i, tc := range tcs {
+		if got := ids(l.Query(tc.q)); !reflect.DeepEqual(got, tc.exp) {
+			t.Errorf("%d: expected %v, got %v", i, tc.exp, got)
+		}
+	}
+}
+
+func TestReqLogMiddleware(t *testing.T) {
+	l := &ReqLog{}
+	l.SetKeepTime(time.Hour)
+
+	root := &Command{
+		Middleware: []Middleware{l.Middleware()},
+		Subcommands: map[string]*Command{
+			"cat": &Command{
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					re.Emit("foo")
+					re.Emit(strings.NewReader("barbaz"))
+					re.Emit(42)
+				},
+			},
+			"fail": &Command{
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					re.SetError("boom", cmdkit.ErrNormal)
+				},
+			},
+		},
+	}
+
+	run := func(path string) *ReqLogEntry {
+		req, err := NewRequest(context.Background(), []string{path}, nil, nil, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rle := l.Add(req)
+		req.Context = rle.Context()
+		re, res := NewChanResponsePair(req)
+		go func() {
+			if err := NewExecutor(root).Execute(req, re, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+		for {
+			v, err := res.Next()
+			if err != nil {
+				break
+			}
+			if r, ok := v.(io.Reader); ok {
+				ioutil.ReadAll(r)
+			}
+		}
+		l.Finish(rle)
+
+		return l.Query(ReqLogQuery{Command: path})[0]
+	}
+
+	if rle := run("cat"); rle.Values != 3 || rle.Bytes != 9 || rle.Error != "" {
+		t.Errorf("expected 3 values with 9 bytes and no error, got %+v", rle)
+	}
+	if rle := run("fail"); rle.Error != "boom" {
+		t.Errorf("expected error %q, got %+v", "boom", rle)
+	}
+}
+
+func TestReqLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "requests")
+
+	open := func() (*ReqLog, *ReqLogFile) {
+		store, err := OpenReqLogFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := &ReqLog{}
+		l.SetKeepTime(time.Hour)
+		if err := l.SetStore(store); err != nil {
+			t.Fatal(err)
+		}
+		return l, store
+	}
+
+	l, store := open()
+	l.Add(&Request{Path: []string{"running"}})
+	l.Finish(l.Add(&Request{Path: []string{"done"}, Arguments: []string{"arg"}}))
+	store.Close()
+
+	// simulate a crash while writing an entry
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"ID":7,"Comm`)
+	f.Close()
+
+	l, store = open()
+	defer store.Close()
+
+	entries := l.Report()
+	if len(entries) != 1 {
+		t.Fatalf("expected one stored entry, got %d", len(entries))
+	}
+	if e := entries[0]; e.ID != 1 || e.Command != "done" || e.Active || !reflect.DeepEqual(e.Args, []string{"arg"}) {
+		t.Errorf("unexpected stored entry %+v", e)
+	}
+	if rle := l.Add(&Request{}); rle.ID != 2 {
+		t.Errorf("expected IDs to continue at 2, got %d", rle.ID)
+	}
+}
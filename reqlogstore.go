@@ -0,0 +1,111 @@
+package cmds
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// ReqLogStore persists the finished entries of a ReqLog, see ReqLog.SetStore.
+type ReqLogStore interface {
+	// Append stores a finished entry.
+	Append(*ReqLogEntry) error
+
+	// Load returns the stored entries, oldest first.
+	Load() ([]*ReqLogEntry, error)
+}
+
+// ReqLogFile is a ReqLogStore that appends entries as JSON lines to a file.
+// The file is never truncated; remove or rotate it while the ReqLog is not
+// using it to limit its size.
+type ReqLogFile struct {
+	lock sync.Mutex
+	f    *os.File
+}
+
+// OpenReqLogFile opens the store at path, creating the file if it doesn't
+// exist.
+func OpenReqLogFile(path string) (*ReqLogFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReqLogFile{f: f}, nil
+}
+
+// Append writes rle to the end of the file.
+func (s *ReqLogFile) Append(rle *ReqLogEntry) error {
+	buf, err := json.Marshal(rle)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, err = s.f.Write(append(buf, '\n'))
+	return err
+}
+
+// Load reads all entries in the file. A truncated last entry, e.g. after a
+// crash while writing it, is ignored.
+func (s *ReqLogFile) Load() ([]*ReqLogEntry, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var out []*ReqLogEntry
+	dec := json.NewDecoder(s.f)
+	for {
+		rle := new(ReqLogEntry)
+		switch err := dec.Decode(rle); err {
+		case nil:
+			out = append(out, rle)
+		case io.EOF, io.ErrUnexpectedEOF:
+			return out, nil
+		default:
+			return out, err
+		}
+	}
+}
+
+// Close closes the file.
+func (s *ReqLogFile) Close() error {
+	return s.f.Close()
+}
+
+// SetStore loads the entries in store into rl and appends every entry that
+// finishes from now on to it. Call it before adding requests; IDs continue
+// after the highest loaded one. The loaded entries are dropped like all others
+// once they are older than the keep time, see SetKeepTime.
+func (rl *ReqLog) SetStore(store ReqLogStore) error {
+	entries, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	for _, rle := range entries {
+		rle.Active = false
+		if rle.ID >= rl.nextID {
+			rl.nextID = rle.ID + 1
+		}
+	}
+
+	// the loaded entries finished before all others
+	finished := append(entries, rl.finishedEntries()...)
+	rl.finished, rl.head, rl.n = nil, 0, 0
+	for _, rle := range finished {
+		rl.push(rle)
+	}
+	rl.store = store
+
+	return nil
+}
@@ -21,6 +21,10 @@ type Request struct {
 	Files files.File
 
 	bodyArgs *arguments
+
+	// optionSources maps option names to the source their value was taken
+	// from by FillDefaults.
+	optionSources map[string]string
 }
 
 // NewRequest returns a request initialized with given arguments
@@ -30,10 +34,11 @@ func NewRequest(ctx context.Context, path []string, opts cmdkit.OptMap, args []s
 		opts = make(cmdkit.OptMap)
 	}
 
-	cmd, err := root.Get(path)
+	cmds, path, err := root.resolve(path)
 	if err != nil {
 		return nil, err
 	}
+	cmd := cmds[len(cmds)-1]
 
 	req := &Request{
 		Path:      path,
@@ -135,7 +140,9 @@ func (req *Request) convertOptions(root *Command) error {
 		}
 	}
 
-	return nil
+	// without sources, FillDefaults only adds default values, which don't
+	// count for the groups
+	return req.checkOptionGroups(optDefs, len(root.OptionSources) == 0)
 }
 
 // GetEncoding returns the EncodingType set in a request, falling back to JSON
@@ -155,8 +162,17 @@ func GetEncoding(req *Request) EncodingType {
 	}
 }
 
-// fillDefault fills in default values if option has not been set
+// FillDefaults fills in values for options that have not been set, taking
+// them from the OptionSources of the root command or, if none of them has a
+// value, from the option's default.
 func (req *Request) FillDefaults() error {
+	return req.FillDefaultsFrom(req.Root.OptionSources...)
+}
+
+// FillDefaultsFrom is FillDefaults with the given sources instead of those of
+// the root command. Servers call it without sources, the values of the
+// client's environment and config have been filled in before sending.
+func (req *Request) FillDefaultsFrom(sources ...OptionSource) error {
 	optDefMap, err := req.Root.GetOptions(req.Path)
 	if err != nil {
 		return err
@@ -168,14 +184,12 @@ func (req *Request) FillDefaults() error {
 		optDefs[optDef] = struct{}{}
 	}
 
+	if req.optionSources == nil {
+		req.optionSources = make(map[string]string)
+	}
+
 Outer:
 	for optDef := range optDefs {
-		dflt := optDef.Default()
-		if dflt == nil {
-			// option has no dflt, continue
-			continue
-		}
-
 		names := optDef.Names()
 		for _, name := range names {
 			if _, ok := req.Options[name]; ok {
@@ -184,8 +198,28 @@ Outer:
 			}
 		}
 
+		for _, src := range sources {
+			v, ok, err := src.Lookup(req, optDef)
+			if err != nil {
+				return err
+			}
+			if ok {
+				req.Options[optDef.Name()] = v
+				req.optionSources[optDef.Name()] = src.Name()
+				continue Outer
+			}
+		}
+
+		dflt := optDef.Default()
+		if dflt == nil {
+			// option has no dflt, continue
+			continue
+		}
+
 		req.Options[optDef.Name()] = dflt
+		req.optionSources[optDef.Name()] = SourceDefault
 	}
 
-	return nil
+	// check the values of the sources, too. Default values are ignored.
+	return req.checkOptionGroups(optDefMap, true)
 }
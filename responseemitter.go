@@ -56,10 +56,19 @@ type Header interface {
 	Head() Head
 }
 
-// Copy sends all values received on res to re. If res is closed, it closes re.
+// Copy sends all values, warnings and progress received on res to re. If res
+// is closed, it closes re.
 func Copy(re ResponseEmitter, res Response) error {
 	re.SetLength(res.Length())
 
+	if pr, ok := res.(ProgressResponse); ok {
+		pr.OnProgress(func(p Progress) {
+			if err := SetProgress(re, p); err != nil {
+				log.Debug("failed to copy progress: ", err)
+			}
+		})
+	}
+
 	for {
 		v, err := res.RawNext()
 		switch err {
@@ -72,7 +81,11 @@ func Copy(re ResponseEmitter, res Response) error {
 			return err
 		}
 
-		err = re.Emit(v)
+		if w, ok := v.(Warning); ok {
+			err = Warn(re, w.Message)
+		} else {
+			err = re.Emit(v)
+		}
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,59 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Timeout returns how long the request may run: the duration given with the
+// timeout option, or the Timeout of the command if the option is not set,
+// capped at its MaxTimeout. Zero means no timeout. Invalid durations are
+// reported as client errors.
+func (req *Request) Timeout() (time.Duration, error) {
+	var timeout, max time.Duration
+	if req.Command != nil {
+		timeout, max = req.Command.Timeout, req.Command.MaxTimeout
+	}
+
+	switch v := req.Options[TimeoutOpt].(type) {
+	case nil:
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return 0, ClientError(fmt.Sprintf("invalid timeout %q: expected a positive duration like 30s or 5m", v))
+		}
+		timeout = d
+	case time.Duration:
+		if v < 0 {
+			return 0, ClientError(fmt.Sprintf("invalid timeout %s: expected a positive duration", v))
+		}
+		timeout = v
+	default:
+		return 0, ClientError(fmt.Sprintf("invalid timeout %v: expected a duration, got %T", v, v))
+	}
+
+	if max > 0 && (timeout == 0 || timeout > max) {
+		timeout = max
+	}
+	return timeout, nil
+}
+
+// WithTimeout returns a shallow copy of req whose context has the deadline
+// of the request's Timeout, if any. The returned function releases the
+// resources of the context and must be called when the request is done.
+func (req *Request) WithTimeout() (*Request, context.CancelFunc, error) {
+	timeout, err := req.Timeout()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if timeout == 0 {
+		return req, func() {}, nil
+	}
+
+	r := *req
+	ctx, cancel := context.WithTimeout(req.Context, timeout)
+	r.Context = ctx
+	return &r, cancel, nil
+}
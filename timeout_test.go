@@ -0,0 +1,118 @@
+package cmds
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	cmd := &Command{Timeout: time.Minute, MaxTimeout: time.Hour}
+
+	tcs := []struct {
+		cmd     *Command
+		opt     interface{}
+		timeout time.Duration
+		err     bool
+	}{
+		{cmd: &Command{}},
+		{cmd: &Command{}, opt: "5s", timeout: 5 * time.Second},
+		{cmd: cmd, timeout: time.Minute},
+		{cmd: cmd, opt: "5s", timeout: 5 * time.Second},
+		{cmd: cmd, opt: 2 * time.Second, timeout: 2 * time.Second},
+		{cmd: cmd, opt: "2h", timeout: time.Hour},
+		{cmd: cmd, opt: "0", timeout: time.Hour},
+		{cmd: &Command{MaxTimeout: time.Hour}, timeout: time.Hour},
+		{cmd: cmd, opt: "soon", err: true},
+		{cmd: cmd, opt: "-1s", err: true},
+		{cmd: cmd, opt: 5, err: true},
+	}
+
+	for _, tc := range tcs {
+		req := &Request{Command: tc.cmd, Options: cmdkit.OptMap{}}
+		if tc.opt != nil {
+			req.Options[TimeoutOpt] = tc.opt
+		}
+
+		timeout, err := req.Timeout()
+		if tc.err {
+			if e, ok := err.(*cmdkit.Error); !ok || e.Code != cmdkit.ErrClient {
+				t.Errorf("timeout %v: expected client error, got %v", tc.opt, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("timeout %v: unexpected error: %s", tc.opt, err)
+		}
+		if timeout != tc.timeout {
+			t.Errorf("timeout %v: expected %s, got %s", tc.opt, tc.timeout, timeout)
+		}
+	}
+}
+
+func TestExecutorTimeout(t *testing.T) {
+	var deadline time.Time
+	root := &Command{
+		Options: []cmdkit.Option{OptionTimeout},
+		Subcommands: map[string]*Command{
+			"wait": &Command{
+				Timeout: time.Minute,
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					deadline, _ = req.Context.Deadline()
+				},
+			},
+		},
+	}
+
+	for _, x := range []Executor{NewExecutor(root), ExecutorFunc(callExecutor(root))} {
+		for opt, exp := range map[string]time.Duration{"": time.Minute, "1s": time.Second} {
+			deadline = time.Time{}
+
+			opts := cmdkit.OptMap{}
+			if opt != "" {
+				opts[TimeoutOpt] = opt
+			}
+			req, err := NewRequest(context.Background(), []string{"wait"}, opts, nil, nil, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			re := NewWriterResponseEmitter(wc{&buf, nopCloser{}}, req, Encoders[JSON])
+			if err := x.Execute(req, re, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			if remaining := time.Until(deadline); remaining <= 0 || remaining > exp {
+				t.Errorf("timeout %q: expected deadline in %s, got %s", opt, exp, remaining)
+			}
+		}
+	}
+
+	req, err := NewRequest(context.Background(), []string{"wait"}, cmdkit.OptMap{TimeoutOpt: "never"}, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := NewWriterResponseEmitter(wc{&bytes.Buffer{}, nopCloser{}}, req, Encoders[JSON])
+	err = NewExecutor(root).Execute(req, re, nil)
+	if e, ok := err.(*cmdkit.Error); !ok || e.Code != cmdkit.ErrClient {
+		t.Errorf("expected client error for invalid timeout, got %v", err)
+	}
+}
+
+func callExecutor(root *Command) func(*Request, ResponseEmitter, Environment) error {
+	return func(req *Request, re ResponseEmitter, env Environment) error {
+		root.Call(req, re, env)
+		return nil
+	}
+}
+
+func TestTimeoutValidate(t *testing.T) {
+	root := &Command{Timeout: time.Hour, MaxTimeout: time.Minute}
+	if errs := root.DebugValidate()[""]; len(errs) != 1 {
+		t.Errorf("expected error for timeout above maximum, got %v", errs)
+	}
+}
@@ -0,0 +1,327 @@
+package cmds
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace, i.e. all spans of a request across processes.
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span that is propagated to child spans, also
+// across processes in the W3C traceparent header.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+
+	// Sampled is set if the caller records its spans.
+	Sampled bool
+}
+
+// IsValid reports whether sc has a trace and span ID.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent returns sc in the format of the W3C traceparent header.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses the value of a W3C traceparent header.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	// later versions may append fields
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+
+	var version, flags [1]byte
+	for _, f := range []struct {
+		dst []byte
+		src string
+	}{
+		{version[:], parts[0]},
+		{sc.TraceID[:], parts[1]},
+		{sc.SpanID[:], parts[2]},
+		{flags[:], parts[3]},
+	} {
+		if len(f.src) != 2*len(f.dst) || strings.ToLower(f.src) != f.src {
+			return sc, fmt.Errorf("invalid traceparent %q", s)
+		}
+		if _, err := hex.Decode(f.dst, []byte(f.src)); err != nil {
+			return sc, fmt.Errorf("invalid traceparent %q", s)
+		}
+	}
+	if version[0] == 0xff || !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// Span is a timed operation within a trace, e.g. running a command.
+type Span struct {
+	SpanContext
+
+	Name       string
+	ParentID   SpanID
+	Start, End time.Time
+	Attributes map[string]string
+
+	// Error is the error the operation failed with, if any.
+	Error string
+
+	exporter SpanExporter
+
+	// lock guards Attributes, Error and End. done is set by Finish.
+	lock sync.Mutex
+	done bool
+}
+
+// SetAttribute sets an attribute of the span, e.g. the command path. Calls
+// after Finish are ignored.
+func (s *Span) SetAttribute(key, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.done {
+		return
+	}
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]string)
+	}
+	s.Attributes[key] = value
+}
+
+// SetError records that the operation failed with err. Calls after Finish are
+// ignored.
+func (s *Span) SetError(err interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.done {
+		return
+	}
+	s.Error = fmt.Sprint(err)
+}
+
+// Finish ends the span and passes it to the exporter of the context it was
+// started in. Calls after the first are ignored. The span doesn't change
+// after it is exported.
+func (s *Span) Finish() {
+	s.lock.Lock()
+	if s.done {
+		s.lock.Unlock()
+		return
+	}
+	s.done = true
+	s.End = time.Now()
+	s.lock.Unlock()
+
+	if s.exporter != nil {
+		s.exporter.ExportSpan(s)
+	}
+}
+
+// SpanExporter receives finished spans, e.g. to send them to a tracing
+// system.
+type SpanExporter interface {
+	ExportSpan(*Span)
+}
+
+// MemorySpanExporter keeps finished spans in memory, e.g. for tests.
+type MemorySpanExporter struct {
+	lock  sync.Mutex
+	spans []*Span
+}
+
+func (e *MemorySpanExporter) ExportSpan(s *Span) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.spans = append(e.spans, s)
+}
+
+// Spans returns the finished spans in the order they finished.
+func (e *MemorySpanExporter) Spans() []*Span {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return append([]*Span(nil), e.spans...)
+}
+
+type (
+	spanContextKey  struct{}
+	spanExporterKey struct{}
+)
+
+// ContextWithSpanContext returns a context whose spans are children of sc,
+// e.g. of a span of the caller in another process.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the current span in ctx.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// ContextWithSpanExporter returns a context whose spans are exported to e.
+func ContextWithSpanExporter(ctx context.Context, e SpanExporter) context.Context {
+	return context.WithValue(ctx, spanExporterKey{}, e)
+}
+
+func spanExporter(ctx context.Context) SpanExporter {
+	e, _ := ctx.Value(spanExporterKey{}).(SpanExporter)
+	return e
+}
+
+// StartSpan starts a span that is a child of the current span in ctx, or the
+// root of a new trace if there is none. It returns a context with the new
+// span as the current one. Spans are only exported if the context has a
+// SpanExporter, but their IDs are always propagated.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	s := &Span{
+		Name:     name,
+		Start:    time.Now(),
+		exporter: spanExporter(ctx),
+	}
+
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		s.TraceID = parent.TraceID
+		s.ParentID = parent.SpanID
+		s.Sampled = parent.Sampled
+	} else {
+		randomID(s.TraceID[:])
+		s.Sampled = s.exporter != nil
+	}
+	randomID(s.SpanID[:])
+
+	return ContextWithSpanContext(ctx, s.SpanContext), s
+}
+
+// ids generates the trace and span IDs. They only need to be unique, so
+// instead of reading crypto/rand for every span, it is seeded from it once.
+var ids = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(idSeed()))}
+
+func idSeed() int64 {
+	var seed [8]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		log.Errorf("failed to seed trace IDs, using the time: %s", err)
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(seed[:]))
+}
+
+// randomID fills id with random bytes, not all of them zero.
+func randomID(id []byte) {
+	ids.Lock()
+	defer ids.Unlock()
+
+	for {
+		ids.Read(id)
+		for _, b := range id {
+			if b != 0 {
+				return
+			}
+		}
+	}
+}
+
+// WithSpan starts a span for the command of req, see StartSpan. It returns a
+// shallow copy of req whose context has the span as the current one.
+func (req *Request) WithSpan(name string) (*Request, *Span) {
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, span := StartSpan(ctx, name)
+	span.SetAttribute("command", strings.Join(req.Path, "/"))
+
+	r := *req
+	r.Context = ctx
+	return &r, span
+}
+
+// tracing reports whether spans started in ctx are exported.
+func tracing(ctx context.Context) bool {
+	return ctx != nil && spanExporter(ctx) != nil
+}
+
+// tracingEncoder records a span for every value encoded by an Encoder.
+type tracingEncoder struct {
+	Encoder
+	ctx context.Context
+}
+
+func (e *tracingEncoder) Encode(v interface{}) error {
+	_, span := StartSpan(e.ctx, "cmds.encode")
+	defer span.Finish()
+
+	err := e.Encoder.Encode(v)
+	if err != nil {
+		span.SetError(err)
+	}
+	return err
+}
+
+// traceEncoder makes the encoders returned by enc record spans in ctx.
+func traceEncoder(ctx context.Context, enc func(io.Writer) Encoder) func(io.Writer) Encoder {
+	if !tracing(ctx) {
+		return enc
+	}
+	return func(w io.Writer) Encoder {
+		return &tracingEncoder{Encoder: enc(w), ctx: ctx}
+	}
+}
+
+// spanCloser finishes a span when the ResponseEmitter is closed.
+type spanCloser struct {
+	ResponseEmitter
+	span *Span
+}
+
+func (re *spanCloser) SetProgress(p Progress) error {
+	return SetProgress(re.ResponseEmitter, p)
+}
+
+func (re *spanCloser) Warn(msg string) error {
+	return Warn(re.ResponseEmitter, msg)
+}
+
+func (re *spanCloser) Close() error {
+	defer re.span.Finish()
+	return re.ResponseEmitter.Close()
+}
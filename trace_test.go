@@ -0,0 +1,185 @@
+package cmds
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tcs := []struct {
+		s       string
+		ok      bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01", false, false},
+	}
+
+	for _, tc := range tcs {
+		sc, err := ParseTraceparent(tc.s)
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: expected ok=%v, got error %v", tc.s, tc.ok, err)
+			continue
+		}
+		if !tc.ok {
+			continue
+		}
+		if sc.Sampled != tc.sampled {
+			t.Errorf("%q: expected sampled=%v", tc.s, tc.sampled)
+		}
+		if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+			t.Errorf("%q: unexpected IDs %s %s", tc.s, sc.TraceID, sc.SpanID)
+		}
+	}
+
+	sc, _ := ParseTraceparent(tcs[0].s)
+	if s := sc.Traceparent(); s != tcs[0].s {
+		t.Errorf("expected %q, got %q", tcs[0].s, s)
+	}
+}
+
+type typedEmitter struct {
+	*WriterResponseEmitter
+}
+
+func (typedEmitter) Type() PostRunType {
+	return CLI
+}
+
+type closeNotifier struct {
+	bytes.Buffer
+	closed chan struct{}
+}
+
+func (w *closeNotifier) Close() error {
+	close(w.closed)
+	return nil
+}
+
+func TestExecutorSpans(t *testing.T) {
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"test": &Command{
+				PreRun: func(req *Request, env Environment) error { return nil },
+				Run: func(req *Request, re ResponseEmitter, env Environment) {
+					re.Emit("foo")
+				},
+				PostRun: PostRunMap{
+					CLI: func(req *Request, re ResponseEmitter) ResponseEmitter {
+						reNext, res := NewChanResponsePair(req)
+						go func() {
+							defer re.Close()
+							for {
+								v, err := res.Next()
+								if err != nil {
+									return
+								}
+								re.Emit(v)
+							}
+						}()
+						return reNext
+					},
+				},
+			},
+		},
+	}
+
+	exp := &MemorySpanExporter{}
+	parent := SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}}
+	ctx := ContextWithSpanContext(ContextWithSpanExporter(context.Background(), exp), parent)
+
+	req, err := NewRequest(ctx, []string{"test"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &closeNotifier{closed: make(chan struct{})}
+	re := typedEmitter{NewWriterResponseEmitter(w, req, Encoders[JSON])}
+	if err := NewExecutor(root).Execute(req, re, nil); err != nil {
+		t.Fatal(err)
+	}
+	<-w.closed
+
+	spans := make(map[string]*Span)
+	for _, s := range exp.Spans() {
+		spans[s.Name] = s
+		if s.TraceID != parent.TraceID {
+			t.Errorf("span %s has trace ID %s, expected %s", s.Name, s.TraceID, parent.TraceID)
+		}
+		if s.End.Before(s.Start) {
+			t.Errorf("span %s ends before it starts", s.Name)
+		}
+	}
+
+	execute := spans["cmds.execute"]
+	if execute == nil || execute.ParentID != parent.SpanID {
+		t.Fatalf("expected execute span as child of %s, got %+v", parent.SpanID, execute)
+	}
+	if cmd := execute.Attributes["command"]; cmd != "test" {
+		t.Errorf("expected command attribute %q, got %q", "test", cmd)
+	}
+	for _, name := range []string{"cmds.prerun", "cmds.run", "cmds.postrun", "cmds.encode"} {
+		if s := spans[name]; s == nil || s.ParentID != execute.SpanID {
+			t.Errorf("expected span %s as child of the execute span, got %+v", name, s)
+		}
+	}
+}
+
+func TestStartSpan(t *testing.T) {
+	ctx, root := StartSpan(context.Background(), "root")
+	if !root.IsValid() || root.ParentID != (SpanID{}) || root.Sampled {
+		t.Errorf("expected unsampled root span with new IDs, got %+v", root)
+	}
+
+	_, child := StartSpan(ctx, "child")
+	if child.TraceID != root.TraceID || child.ParentID != root.SpanID || child.SpanID == root.SpanID {
+		t.Errorf("expected child of %+v, got %+v", root.SpanContext, child)
+	}
+
+	// finishing without an exporter is fine, and only the first call counts
+	child.Finish()
+	end := child.End
+	child.Finish()
+	if child.End != end {
+		t.Error("expected second Finish to be ignored")
+	}
+}
+
+func TestSpanConcurrent(t *testing.T) {
+	e := &MemorySpanExporter{}
+	_, span := StartSpan(ContextWithSpanExporter(context.Background(), e), "span")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			span.SetAttribute("i", strconv.Itoa(i))
+			span.SetError(i)
+		}
+	}()
+	span.Finish()
+	<-done
+
+	// the exported span doesn't change anymore
+	spans := e.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	attr, err := spans[0].Attributes["i"], spans[0].Error
+	span.SetAttribute("i", "late")
+	span.SetError("late")
+	if spans[0].Attributes["i"] != attr || spans[0].Error != err {
+		t.Error("expected the span to be left alone after Finish")
+	}
+}
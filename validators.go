@@ -0,0 +1,134 @@
+package cmds
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+// Validator checks the value of a string argument. The returned error should
+// describe what is wrong with the value; CheckArguments adds the name of the
+// argument.
+type Validator func(value string) error
+
+// OneOf returns a Validator that accepts only the given values.
+func OneOf(values ...string) Validator {
+	return func(value string) error {
+		for _, v := range values {
+			if v == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}
+}
+
+// MatchRegexp returns a Validator that accepts values matching expr. It
+// panics if expr cannot be compiled.
+func MatchRegexp(expr string) Validator {
+	re := regexp.MustCompile(expr)
+	return func(value string) error {
+		if !re.MatchString(value) {
+			return fmt.Errorf("must match %s", expr)
+		}
+		return nil
+	}
+}
+
+// IntRange returns a Validator that accepts integers between min and max,
+// inclusive.
+func IntRange(min, max int64) Validator {
+	return func(value string) error {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || i < min || i > max {
+			return fmt.Errorf("must be an integer between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
+// FloatRange returns a Validator that accepts numbers between min and max,
+// inclusive.
+func FloatRange(min, max float64) Validator {
+	return func(value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < min || f > max {
+			return fmt.Errorf("must be a number between %g and %g", min, max)
+		}
+		return nil
+	}
+}
+
+// validateArguments runs the ArgumentValidators of the command on the string
+// arguments of the request.
+func (c *Command) validateArguments(req *Request) error {
+	if len(c.ArgumentValidators) == 0 {
+		return nil
+	}
+
+	for i, argDef := range stringArgDefs(c.Arguments, len(req.Arguments)) {
+		for _, validate := range c.ArgumentValidators[argDef.Name] {
+			if err := validate(req.Arguments[i]); err != nil {
+				return &cmdkit.Error{
+					Code:    cmdkit.ErrClient,
+					Message: fmt.Sprintf("invalid argument %q: %s", argDef.Name, err),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// stringArgDefs returns the argument definition for each of n string
+// arguments, skipping optional arguments if there are not enough values, the
+// same way the parsers assign them.
+func stringArgDefs(argDefs []cmdkit.Argument, n int) []cmdkit.Argument {
+	var defs []cmdkit.Argument
+	remRequired := 0
+	for _, argDef := range argDefs {
+		if argDef.Type == cmdkit.ArgString {
+			defs = append(defs, argDef)
+			if argDef.Required {
+				remRequired++
+			}
+		}
+	}
+
+	if len(defs) == 0 {
+		return nil
+	}
+
+	out := make([]cmdkit.Argument, 0, n)
+	iDef := 0
+	for i := 0; i < n; i++ {
+		if iDef >= len(defs) {
+			// the last definition is variadic or there are too many values
+			if !defs[len(defs)-1].Variadic {
+				break
+			}
+			out = append(out, defs[len(defs)-1])
+			continue
+		}
+
+		// skip optional argument definitions if there aren't sufficient remaining values
+		for n-i <= remRequired && !defs[iDef].Required && iDef < len(defs)-1 {
+			iDef++
+		}
+
+		argDef := defs[iDef]
+		if argDef.Required {
+			remRequired--
+		}
+		out = append(out, argDef)
+
+		if !argDef.Variadic {
+			iDef++
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,113 @@
+package cmds
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+)
+
+func TestValidators(t *testing.T) {
+	type testcase struct {
+		v     Validator
+		value string
+		ok    bool
+	}
+
+	tcs := []testcase{
+		{v: OneOf("a", "b"), value: "a", ok: true},
+		{v: OneOf("a", "b"), value: "c", ok: false},
+		{v: MatchRegexp("^Qm[a-zA-Z0-9]+$"), value: "QmFoo", ok: true},
+		{v: MatchRegexp("^Qm[a-zA-Z0-9]+$"), value: "foo", ok: false},
+		{v: IntRange(1, 10), value: "10", ok: true},
+		{v: IntRange(1, 10), value: "11", ok: false},
+		{v: IntRange(1, 10), value: "one", ok: false},
+		{v: FloatRange(0, 1), value: "0.5", ok: true},
+		{v: FloatRange(0, 1), value: "-0.5", ok: false},
+	}
+
+	for i, tc := range tcs {
+		err := tc.v(tc.value)
+		if tc.ok && err != nil {
+			t.Errorf("%d: expected %q to be valid, got %v", i, tc.value, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("%d: expected %q to be invalid", i, tc.value)
+		}
+	}
+}
+
+func TestCheckArgumentsValidators(t *testing.T) {
+	cmd := &Command{
+		Arguments: []cmdkit.Argument{
+			cmdkit.StringArg("mode", false, false, "the mode"),
+			cmdkit.StringArg("count", true, false, "the count"),
+			cmdkit.StringArg("names", false, true, "the names"),
+		},
+		ArgumentValidators: map[string][]Validator{
+			"mode":  {OneOf("fast", "slow")},
+			"count": {IntRange(1, 3)},
+			"names": {func(value string) error {
+				if value == "root" {
+					return errors.New("is reserved")
+				}
+				return nil
+			}},
+		},
+		Run: noop,
+	}
+
+	type testcase struct {
+		args []string
+		err  string
+	}
+
+	tcs := []testcase{
+		{args: []string{"2"}},
+		{args: []string{"4"}, err: `invalid argument "count": must be an integer between 1 and 3`},
+		{args: []string{"fast", "2"}},
+		{args: []string{"quick", "2"}, err: `invalid argument "mode": must be one of fast, slow`},
+		{args: []string{"slow", "1", "a", "b"}},
+		{args: []string{"slow", "1", "a", "root"}, err: `invalid argument "names": is reserved`},
+	}
+
+	for _, tc := range tcs {
+		req, err := NewRequest(context.Background(), nil, nil, tc.args, nil, cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = cmd.CheckArguments(req)
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", tc.args, err)
+			}
+			continue
+		}
+
+		e, ok := err.(*cmdkit.Error)
+		if !ok {
+			t.Errorf("%v: expected *cmdkit.Error, got %v", tc.args, err)
+			continue
+		}
+		if e.Code != cmdkit.ErrClient || e.Message != tc.err {
+			t.Errorf("%v: expected client error %q, got %v", tc.args, tc.err, e)
+		}
+	}
+
+	// Call keeps the code of the validation error
+	req, err := NewRequest(context.Background(), nil, nil, []string{"4"}, nil, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, res := NewChanResponsePair(req)
+	go cmd.Call(req, re, nil)
+
+	if _, err := res.Next(); err != ErrRcvdError {
+		t.Fatalf("expected %v, got %v", ErrRcvdError, err)
+	}
+	if e := res.Error(); e.Code != cmdkit.ErrClient {
+		t.Errorf("expected client error, got %#v", e)
+	}
+}
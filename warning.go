@@ -0,0 +1,74 @@
+package cmds
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Warning is a non-fatal problem of a command that the user should know
+// about. Unlike an error, it doesn't make the command fail. It is sent
+// besides the values a command emits, see Warn.
+type Warning struct {
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Message
+}
+
+// MarshalJSON marks the encoded value as a warning, so it can be told apart
+// from the values of a command.
+func (w Warning) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Message string
+		Type    string
+	}{w.Message, "warning"})
+}
+
+func (w *Warning) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Message string
+		Type    string
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Type != "warning" {
+		return errors.New("not of type warning")
+	}
+
+	w.Message = v.Message
+	return nil
+}
+
+// WarningEmitter is a ResponseEmitter that can send warnings to the client
+// besides the emitted values. All emitters in this package and its
+// subpackages are.
+type WarningEmitter interface {
+	ResponseEmitter
+
+	// Warn sends a warning to the client. It doesn't end the response or
+	// change its error.
+	Warn(msg string) error
+}
+
+// WarningResponse is a Response that collects the warnings it receives.
+// Next skips them, RawNext returns them as Warning values.
+type WarningResponse interface {
+	Response
+
+	// Warnings returns the warnings received so far, in order.
+	Warnings() []string
+}
+
+// Warn sends a warning on re. If re can't send warnings, the warning is
+// logged instead.
+func Warn(re ResponseEmitter, msg string) error {
+	we, ok := re.(WarningEmitter)
+	if !ok {
+		log.Warning(msg)
+		return nil
+	}
+
+	return we.Warn(msg)
+}
@@ -0,0 +1,223 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestWarningJSON(t *testing.T) {
+	w := Warning{Message: "careful"}
+
+	buf, err := json.Marshal(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"Message":"careful","Type":"warning"}`; string(buf) != exp {
+		t.Errorf("expected %s, got %s", exp, buf)
+	}
+
+	m := &MaybeError{}
+	if err := json.Unmarshal(buf, m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.Get(); v != w {
+		t.Errorf("expected %v, got %v", w, v)
+	}
+
+	// values with the same fields are no warnings
+	var value struct{ Message string }
+	m = &MaybeError{Value: &value}
+	if err := json.Unmarshal([]byte(`{"Message":"hi"}`), m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Get().(Warning); ok || value.Message != "hi" {
+		t.Errorf("expected value, got %#v", m.Get())
+	}
+}
+
+func TestChanWarnings(t *testing.T) {
+	req := &Request{}
+	re, res := NewChanResponsePair(req)
+	copyRe, copyRes := NewChanResponsePair(req)
+
+	go func() {
+		Warn(re, "first")
+		re.Emit("a")
+		Warn(re, "second")
+		re.Emit("b")
+		re.Close()
+	}()
+	go func() {
+		if err := Copy(copyRe, res); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var values []interface{}
+	for {
+		v, err := copyRes.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+
+	if exp := []interface{}{"a", "b"}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("expected values %v, got %v", exp, values)
+	}
+
+	exp := []string{"first", "second"}
+	if ws := copyRes.(WarningResponse).Warnings(); !reflect.DeepEqual(ws, exp) {
+		t.Errorf("expected warnings %v, got %v", exp, ws)
+	}
+	if ws := res.(WarningResponse).Warnings(); !reflect.DeepEqual(ws, exp) {
+		t.Errorf("expected warnings %v on the copied response, got %v", exp, ws)
+	}
+	if err := copyRes.Error(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestChanWarningsConcurrent(t *testing.T) {
+	re, res := NewChanResponsePair(&Request{})
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			Warn(re, "careful")
+		}
+		re.Close()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, err := res.Next(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Warnings may be called while the response is read
+	for {
+		select {
+		case <-done:
+			if n := len(res.(WarningResponse).Warnings()); n != 100 {
+				t.Errorf("expected 100 warnings, got %d", n)
+			}
+			return
+		default:
+			res.(WarningResponse).Warnings()
+		}
+	}
+}
+
+type typedChanEmitter struct {
+	*chanResponseEmitter
+}
+
+func (typedChanEmitter) Type() PostRunType {
+	return CLI
+}
+
+func TestPostRunForwarding(t *testing.T) {
+	root := &Command{
+		Run: func(req *Request, re ResponseEmitter, env Environment) {
+			SetProgress(re, Progress{Done: 1, Total: 2})
+			Warn(re, "careful")
+			re.Emit("a")
+		},
+		PostRun: PostRunMap{
+			CLI: func(req *Request, re ResponseEmitter) ResponseEmitter {
+				reNext, res := NewChanResponsePair(req)
+				go func() {
+					defer re.Close()
+					for {
+						v, err := res.Next()
+						if err != nil {
+							return
+						}
+						re.Emit(v)
+					}
+				}()
+				return reNext
+			},
+		},
+	}
+
+	req, err := NewRequest(context.Background(), nil, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	re, res := NewChanResponsePair(req)
+	var progress []Progress
+	res.(ProgressResponse).OnProgress(func(p Progress) {
+		progress = append(progress, p)
+	})
+
+	go func() {
+		if err := NewExecutor(root).Execute(req, typedChanEmitter{re.(*chanResponseEmitter)}, nil); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var values []interface{}
+	if err := Collect(res, &values); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := []interface{}{"a"}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("expected values %v, got %v", exp, values)
+	}
+	if exp := []Progress{{Done: 1, Total: 2}}; !reflect.DeepEqual(progress, exp) {
+		t.Errorf("expected progress %v to pass the PostRun, got %v", exp, progress)
+	}
+	if ws, exp := res.(WarningResponse).Warnings(), []string{"careful"}; !reflect.DeepEqual(ws, exp) {
+		t.Errorf("expected warnings %v to pass the PostRun, got %v", exp, ws)
+	}
+}
+
+func TestReaderWarnings(t *testing.T) {
+	req := &Request{Command: &Command{}}
+	r, w := io.Pipe()
+	re := NewWriterResponseEmitter(w, req, Encoders[JSON])
+	res := NewReaderResponse(r, JSON, req)
+
+	// warnings don't go through the encoder of the command
+	re.SetEncoder(MakeTypedEncoder(func(req *Request, w io.Writer, s string) error {
+		return json.NewEncoder(w).Encode(s)
+	})(req))
+
+	go func() {
+		re.Emit("a")
+		Warn(re, "careful")
+		re.Emit("b")
+		re.Close()
+	}()
+
+	var values []interface{}
+	for {
+		v, err := res.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+
+	if exp := []interface{}{"a", "b"}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("expected values %v, got %v", exp, values)
+	}
+	if ws, exp := res.(WarningResponse).Warnings(), []string{"careful"}; !reflect.DeepEqual(ws, exp) {
+		t.Errorf("expected warnings %v, got %v", exp, ws)
+	}
+}
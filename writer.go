@@ -43,8 +43,9 @@ type readerResponse struct {
 
 	req *Request
 
-	length uint64
-	err    *cmdkit.Error
+	length   uint64
+	err      *cmdkit.Error
+	warnings []string
 
 	emitted chan struct{}
 	once    sync.Once
@@ -76,6 +77,11 @@ func (r *readerResponse) RawNext() (interface{}, error) {
 	r.once.Do(func() { close(r.emitted) })
 
 	v := m.Get()
+	if w, ok := v.(Warning); ok {
+		r.warnings = append(r.warnings, w.Message)
+		return w, nil
+	}
+
 	// because working with pointers to arrays is annoying
 	if t := reflect.TypeOf(v); t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice {
 		v = reflect.ValueOf(v).Elem().Interface()
@@ -97,6 +103,8 @@ func (r *readerResponse) Next() (interface{}, error) {
 	case *cmdkit.Error:
 		r.err = val
 		return nil, ErrRcvdError
+	case Warning:
+		return r.Next()
 	case Single:
 		return val.Value, nil
 	default:
@@ -104,6 +112,11 @@ func (r *readerResponse) Next() (interface{}, error) {
 	}
 }
 
+// Warnings returns the warnings read so far.
+func (r *readerResponse) Warnings() []string {
+	return append([]string(nil), r.warnings...)
+}
+
 type WriterResponseEmitter struct {
 	// TODO maybe make those public?
 	w   io.Writer
@@ -128,6 +141,19 @@ func (re *WriterResponseEmitter) SetError(v interface{}, errType cmdkit.ErrorTyp
 	}
 }
 
+// Warn writes a warning between the values. It is encoded with the builtin
+// encoder of the request's encoding, not the one set by SetEncoder, which may
+// only handle the command's values. With other encodings it is logged.
+func (re *WriterResponseEmitter) Warn(msg string) error {
+	enc := GetEncoding(re.req)
+	if !IsFrameEncoding(enc) {
+		log.Warning(msg)
+		return nil
+	}
+
+	return Encoders[enc](re.req)(re.w).Encode(Warning{msg})
+}
+
 func (re *WriterResponseEmitter) SetLength(length uint64) {
 	if re.emitted {
 		return
@@ -172,16 +198,26 @@ func (re *WriterResponseEmitter) Emit(v interface{}) error {
 }
 
 type MaybeError struct {
-	Value interface{} // needs to be a pointer
-	Error cmdkit.Error
-
-	isError bool
+	Value    interface{} // needs to be a pointer
+	Error    cmdkit.Error
+	Progress Progress
+	Warning  Warning
+
+	isError    bool
+	isProgress bool
+	isWarning  bool
 }
 
 func (m *MaybeError) Get() interface{} {
 	if m.isError {
 		return m.Error
 	}
+	if m.isProgress {
+		return m.Progress
+	}
+	if m.isWarning {
+		return m.Warning
+	}
 	return m.Value
 }
 
@@ -192,6 +228,18 @@ func (m *MaybeError) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 
+	err = json.Unmarshal(data, &m.Progress)
+	if err == nil {
+		m.isProgress = true
+		return nil
+	}
+
+	err = json.Unmarshal(data, &m.Warning)
+	if err == nil {
+		m.isWarning = true
+		return nil
+	}
+
 	if m.Value != nil {
 		// make sure we are working with a pointer here
 		v := reflect.ValueOf(m.Value)